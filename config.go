@@ -0,0 +1,316 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/lib/zapr"
+	"github.com/tomhjx/xlog/option"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete logging configuration. It covers the same
+// settings as the setters in setting.go and the flags from InitFlags.
+//
+// Use CurrentConfig to start from the active configuration and
+// Configure to apply a modified one. The field tags define the keys
+// of configuration files read by LoadConfigFile.
+type Config struct {
+	// Verbosity is the V logging level.
+	Verbosity Level `json:"verbosity" yaml:"verbosity"`
+	// VModule overrides Verbosity per file or package, see SetVModule.
+	VModule string `json:"vmodule" yaml:"vmodule"`
+	// VName overrides Verbosity for named loggers, see SetNameVerbosity.
+	VName string `json:"vname" yaml:"vname"`
+	// Severity is the threshold below which entries are dropped. Empty
+	// means INFO.
+	Severity string `json:"severity" yaml:"severity"`
+
+	// StderrThreshold is the threshold for entries written to stderr
+	// when File is written to as well, like Severity. Empty means
+	// ERROR.
+	StderrThreshold string `json:"stderrThreshold" yaml:"stderrThreshold"`
+	// LogToStderr writes all entries to stderr and none to File.
+	LogToStderr bool `json:"logToStderr" yaml:"logToStderr"`
+	// AlsoLogToStderr writes all entries to stderr and to File,
+	// regardless of StderrThreshold.
+	AlsoLogToStderr bool `json:"alsoLogToStderr" yaml:"alsoLogToStderr"`
+
+	// File is written to in addition to stderr if not empty.
+	File string `json:"file" yaml:"file"`
+	// FileMaxSizeMB is the size at which File gets rotated, 0 uses the
+	// default of 100 MB.
+	FileMaxSizeMB int `json:"fileMaxSizeMB" yaml:"fileMaxSizeMB"`
+	// FileMaxAgeDay is the number of days rotated files are retained,
+	// 0 retains them regardless of their age.
+	FileMaxAgeDay int `json:"fileMaxAgeDay" yaml:"fileMaxAgeDay"`
+	// FileMaxBackups is the number of rotated files that are retained,
+	// 0 retains all of them.
+	FileMaxBackups int `json:"fileMaxBackups" yaml:"fileMaxBackups"`
+	// FileCompress gzips rotated files.
+	FileCompress bool `json:"fileCompress" yaml:"fileCompress"`
+	// FileUTC uses UTC instead of the local time in the names of rotated
+	// files.
+	FileUTC bool `json:"fileUTC" yaml:"fileUTC"`
+	// FileMode is the octal permission of log files, like "0640". Empty
+	// means 0600.
+	FileMode string `json:"fileMode" yaml:"fileMode"`
+
+	// BacktraceAt is a call site like "gopher.go:42", see SetBacktraceAt.
+	BacktraceAt string `json:"backtraceAt" yaml:"backtraceAt"`
+
+	// Contextual enables contextual logging.
+	Contextual bool `json:"contextual" yaml:"contextual"`
+	// Format is "json", "text", "logfmt" or "console". Empty means
+	// "console" on a terminal and "json" otherwise.
+	Format string `json:"format" yaml:"format"`
+	// Color is "auto", "always" or "never" for the console format.
+	Color string `json:"color" yaml:"color"`
+	// Encoder customizes the "json" format.
+	Encoder option.EncoderConfig `json:"encoder" yaml:"encoder"`
+}
+
+// Validate checks that c can be applied with Configure. All problems are
+// reported, not just the first one.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Verbosity < 0 {
+		errs = append(errs, fmt.Errorf("verbosity %d is negative", c.Verbosity))
+	}
+	if _, err := parseModuleSpec(c.VModule); err != nil {
+		errs = append(errs, fmt.Errorf("vmodule: %w", err))
+	}
+	if _, err := zapr.ParseNameLevels(c.VName); err != nil {
+		errs = append(errs, fmt.Errorf("vname: %w", err))
+	}
+	if _, _, err := zapr.ParseTraceLocation(c.BacktraceAt); err != nil {
+		errs = append(errs, fmt.Errorf("backtrace at: %w", err))
+	}
+	if c.Severity != "" {
+		if _, err := parseSeverity(c.Severity); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.StderrThreshold != "" {
+		if _, err := parseSeverity(c.StderrThreshold); err != nil {
+			errs = append(errs, fmt.Errorf("stderr threshold: %w", err))
+		}
+	}
+	for name, v := range map[string]int{
+		"file max size":    c.FileMaxSizeMB,
+		"file max age":     c.FileMaxAgeDay,
+		"file max backups": c.FileMaxBackups,
+	} {
+		if v < 0 {
+			errs = append(errs, fmt.Errorf("%s %d is negative", name, v))
+		}
+	}
+	if _, err := parseFileMode(c.FileMode); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Format {
+	case "", option.EncodingJSON, option.EncodingText, option.EncodingLogfmt, option.EncodingConsole:
+	default:
+		errs = append(errs, fmt.Errorf("unknown format %q", c.Format))
+	}
+	switch c.Color {
+	case "", option.ColorAuto, option.ColorAlways, option.ColorNever:
+	default:
+		errs = append(errs, fmt.Errorf("unknown color mode %q", c.Color))
+	}
+	if _, err := zapr.NewJSONEncoderConfig(c.Encoder); err != nil {
+		errs = append(errs, fmt.Errorf("encoder: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid logging configuration: %w", err)
+	}
+	return nil
+}
+
+// CurrentConfig returns the active logging configuration.
+func CurrentConfig() Config {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	return Config{
+		Verbosity:       logging.verbosity.get(),
+		VModule:         logging.vmodule.string(),
+		VName:           logging.vname.String(),
+		Severity:        severityName(logging.severity.get()),
+		StderrThreshold: severityName(logging.stderrThreshold.get()),
+		LogToStderr:     logging.toStderr,
+		AlsoLogToStderr: logging.alsoToStderr,
+		File:            logging.file,
+		FileMaxSizeMB:   logging.fileMaxSizeMB,
+		FileMaxAgeDay:   logging.fileMaxAgeDay,
+		FileMaxBackups:  logging.fileMaxBackups,
+		FileCompress:    logging.fileCompress,
+		FileUTC:         logging.fileUTC,
+		FileMode:        formatFileMode(logging.fileMode),
+		BacktraceAt:     logging.backtraceAt.String(),
+		Contextual:      logging.contextualLoggingEnabled.Load(),
+		Format:          logging.format,
+		Color:           logging.color,
+		Encoder:         logging.encoder,
+	}
+}
+
+// Configure validates c, builds a new global logger for it and then
+// applies all settings in one step, like Reconfigure does for the
+// current settings. If c is invalid or the logger cannot be built,
+// nothing is changed.
+func Configure(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	filter, _ := parseModuleSpec(c.VModule)
+	nameLevels, _ := zapr.ParseNameLevels(c.VName)
+	s := severity.InfoLog
+	if c.Severity != "" {
+		s, _ = parseSeverity(c.Severity)
+	}
+	stderrThreshold := severity.ErrorLog
+	if c.StderrThreshold != "" {
+		stderrThreshold, _ = parseSeverity(c.StderrThreshold)
+	}
+
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	next := logging.settings
+	c.applyOutputs(&next)
+	logger, err := zapr.Build(next.logOption())
+	if err != nil {
+		return fmt.Errorf("build logger: %w", err)
+	}
+
+	logging.stopRevert(&logging.verbosityRevert)
+	logging.setVState(c.Verbosity, filter, true)
+	logging.vname.Set(nameLevels)
+	logging.backtraceAt.Set(c.BacktraceAt)
+	logging.stopRevert(&logging.severityRevert)
+	logging.severity.set(s)
+	logging.stderrThreshold.set(stderrThreshold)
+	c.applyOutputs(&logging.settings)
+	logging.contextualLoggingEnabled.Store(c.Contextual)
+	logging.replaceLogger(&logWriter{Logger: logger, built: true})
+	return nil
+}
+
+// applyOutputs copies the settings which describe the log outputs
+// from c to s. c must be valid.
+func (c Config) applyOutputs(s *settings) {
+	s.toStderr = c.LogToStderr
+	s.alsoToStderr = c.AlsoLogToStderr
+	s.file = c.File
+	s.fileMaxSizeMB = c.FileMaxSizeMB
+	s.fileMaxAgeDay = c.FileMaxAgeDay
+	s.fileMaxBackups = c.FileMaxBackups
+	s.fileCompress = c.FileCompress
+	s.fileUTC = c.FileUTC
+	s.fileMode, _ = parseFileMode(c.FileMode)
+	s.format = c.Format
+	s.color = c.Color
+	s.encoder = c.Encoder
+}
+
+// severityName returns the name used for s in a Config.
+func severityName(s severity.Severity) string {
+	return severity.Name[s]
+}
+
+// parseFileMode parses an octal file permission. The empty string is 0.
+func parseFileMode(value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || m > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode %q", value)
+	}
+	return os.FileMode(m), nil
+}
+
+// formatFileMode is the reverse of parseFileMode.
+func formatFileMode(m os.FileMode) string {
+	if m == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%#o", uint32(m))
+}
+
+// logOption describes the output of the global logger for s.
+func (s *settings) logOption() option.LogOption {
+	op := option.LogOption{
+		OutputPath:    s.file,
+		MaxSizeMB:     s.fileMaxSizeMB,
+		MaxAgeDay:     s.fileMaxAgeDay,
+		MaxBackups:    s.fileMaxBackups,
+		Compress:      s.fileCompress,
+		UTC:           s.fileUTC,
+		FileMode:      s.fileMode,
+		Encoding:      s.format,
+		Color:         s.color,
+		EncoderConfig: s.encoder,
+		Level:         levelEnabler{severity: s.severity.level, verbosity: &logging.verbosity},
+		NameLevels:    s.vname,
+		BacktraceAt:   s.backtraceAt,
+	}
+	if s.toStderr {
+		op.OutputPath = ""
+	}
+	if op.OutputPath != "" && !s.alsoToStderr {
+		op.StderrLevel = s.stderrThreshold.level
+	}
+	return op
+}
+
+// LoadConfigFile reads the logging configuration from the YAML or JSON
+// file at path and applies it with Configure. Files ending in ".json"
+// are parsed as JSON, everything else as YAML. Keys missing from the
+// file keep their current value, unknown keys are rejected.
+func LoadConfigFile(path string) error {
+	c, err := readConfigFile(path, CurrentConfig())
+	if err != nil {
+		return err
+	}
+	if err := Configure(c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readConfigFile parses the file at path on top of base.
+func readConfigFile(path string, base Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := base
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&c)
+		if err == io.EOF {
+			// An empty file changes nothing.
+			err = nil
+		}
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
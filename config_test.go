@@ -0,0 +1,273 @@
+package xlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/option"
+)
+
+// saveConfig restores the current configuration when the test ends and
+// returns it. The global logger gets built anew on its next use.
+func saveConfig(t *testing.T) Config {
+	prev := CurrentConfig()
+	t.Cleanup(func() {
+		assert.NoError(t, Configure(prev))
+		ClearLogger()
+	})
+	return prev
+}
+
+// logToFile makes the global logger write to a new file until the test
+// ends and returns the path of the file.
+func logToFile(t *testing.T, name string) string {
+	logFile := filepath.Join(t.TempDir(), name)
+	saveConfig(t)
+	SetFile(logFile)
+	assert.NoError(t, Reconfigure())
+	return logFile
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"zero":             {config: Config{}},
+		"full":             {config: Config{Verbosity: 4, Severity: "warning", File: "app.log", FileMaxSizeMB: 10, FileMaxAgeDay: 7, FileMaxBackups: 3, Contextual: true, Format: "json"}},
+		"numeric severity": {config: Config{Severity: "2"}},
+		"negative v":       {config: Config{Verbosity: -1}, wantErr: true},
+		"vmodule":          {config: Config{VModule: "gopher*=3,github.com/example/*=1"}},
+		"bad vmodule":      {config: Config{VModule: "gopher=x"}, wantErr: true},
+		"vname":            {config: Config{VName: "controller.reconciler=4,cache=0"}},
+		"bad vname":        {config: Config{VName: "cache=-1"}, wantErr: true},
+		"backtrace at":     {config: Config{BacktraceAt: "gopher.go:42"}},
+		"bad backtrace at": {config: Config{BacktraceAt: "gopher.go:-1"}, wantErr: true},
+		"stderr threshold": {config: Config{StderrThreshold: "warning", LogToStderr: true, AlsoLogToStderr: true}},
+		"bad stderr":       {config: Config{StderrThreshold: "loud"}, wantErr: true},
+		"unknown severity": {config: Config{Severity: "loud"}, wantErr: true},
+		"severity range":   {config: Config{Severity: "9"}, wantErr: true},
+		"negative size":    {config: Config{FileMaxSizeMB: -1}, wantErr: true},
+		"negative age":     {config: Config{FileMaxAgeDay: -1}, wantErr: true},
+		"negative backups": {config: Config{FileMaxBackups: -1}, wantErr: true},
+		"text format":      {config: Config{Format: "text"}},
+		"logfmt format":    {config: Config{Format: "logfmt"}},
+		"console format":   {config: Config{Format: "console", Color: "never"}},
+		"unknown format":   {config: Config{Format: "xml"}, wantErr: true},
+		"unknown color":    {config: Config{Color: "blue"}, wantErr: true},
+		"encoder":          {config: Config{Encoder: option.EncoderConfig{TimeKey: "@timestamp", TimeEncoding: "2006-01-02T15:04:05"}}},
+		"bad encoder":      {config: Config{Encoder: option.EncoderConfig{TimeEncoding: "iso", LevelEncoding: "color"}}, wantErr: true},
+		"file mode":        {config: Config{FileMode: "0640"}},
+		"bad file mode":    {config: Config{FileMode: "rw-r-----"}, wantErr: true},
+		"large file mode":  {config: Config{FileMode: "01777"}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	saveConfig(t)
+	logFile := filepath.Join(t.TempDir(), "configure.log")
+	c := Config{
+		Verbosity:       2,
+		Severity:        "WARNING",
+		StderrThreshold: "FATAL",
+		File:            logFile,
+		Contextual:      true,
+	}
+	assert.NoError(t, Configure(c))
+	assert.Equal(t, c, CurrentConfig())
+	assert.Equal(t, severity.WarningLog, logging.severity.get())
+
+	Warning("configured")
+	Info("dropped")
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "configured")
+	assert.NotContains(t, string(r), "dropped")
+
+	// An invalid configuration is rejected as a whole.
+	bad := c
+	bad.Verbosity = 5
+	bad.Format = "xml"
+	assert.Error(t, Configure(bad))
+	assert.Equal(t, c, CurrentConfig())
+}
+
+func TestConfigureClosesOutputs(t *testing.T) {
+	prev := saveConfig(t)
+	logFile := filepath.Join(t.TempDir(), "reconfigure.log")
+	c := prev
+	c.Severity = "INFO"
+	c.File = logFile
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, Configure(c))
+		Info("configured")
+	}
+	assert.Equal(t, 1, openFiles(t, logFile))
+}
+
+func TestConfigureKeepsDerivedLoggers(t *testing.T) {
+	prev := saveConfig(t)
+	c := prev
+	c.Severity = "INFO"
+	c.File = filepath.Join(t.TempDir(), "derived.log")
+	c.Contextual = true
+	assert.NoError(t, Configure(c))
+	ctx := NewContext(context.Background(), LoggerWithName(Background(), "worker"))
+
+	// Reloads which only change the verbosity keep the file open for
+	// loggers derived from a previous global logger.
+	for i := 0; i < 3; i++ {
+		c.Verbosity = Level(i)
+		assert.NoError(t, Configure(c))
+		FromContext(ctx).Info("still logging", "reload", i)
+	}
+	r, err := os.ReadFile(c.File)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.Contains(t, string(r), fmt.Sprintf(`"reload":%d`, i))
+	}
+	assert.Equal(t, 1, openFiles(t, c.File))
+}
+
+// openFiles returns how often the process has path open.
+func openFiles(t *testing.T, path string) int {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("no /proc/self/fd:", err)
+	}
+	n := 0
+	for _, fd := range fds {
+		if target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); target == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	saveConfig(t)
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+
+	yamlFile := write("xlog.yaml", `
+verbosity: 3
+severity: error
+stderrThreshold: warning
+alsoLogToStderr: true
+file: `+logFile+`
+fileMaxSizeMB: 20
+fileMaxAgeDay: 2
+fileMaxBackups: 4
+contextual: false
+format: json
+color: always
+encoder:
+  timeKey: "@timestamp"
+  timeEncoding: rfc3339nano
+`)
+	assert.NoError(t, LoadConfigFile(yamlFile))
+	assert.Equal(t, Config{
+		Verbosity:       3,
+		Severity:        "ERROR",
+		StderrThreshold: "WARNING",
+		AlsoLogToStderr: true,
+		File:            logFile,
+		FileMaxSizeMB:   20,
+		FileMaxAgeDay:   2,
+		FileMaxBackups:  4,
+		Format:          "json",
+		Color:           "always",
+		Encoder:         option.EncoderConfig{TimeKey: "@timestamp", TimeEncoding: "rfc3339nano"},
+	}, CurrentConfig())
+
+	// Missing keys keep their value.
+	jsonFile := write("xlog.json", `{"verbosity": 1, "contextual": true}`)
+	assert.NoError(t, LoadConfigFile(jsonFile))
+	c := CurrentConfig()
+	assert.Equal(t, Level(1), c.Verbosity)
+	assert.Equal(t, "ERROR", c.Severity)
+	assert.Equal(t, logFile, c.File)
+	assert.True(t, c.Contextual)
+
+	assert.NoError(t, LoadConfigFile(write("empty.yaml", "")))
+	assert.Equal(t, c, CurrentConfig())
+
+	for name, content := range map[string]string{
+		"unknown.yaml": "verbose: 3\n",
+		"invalid.yaml": "severity: loud\n",
+		"syntax.yaml":  "verbosity: [\n",
+		"unknown.json": `{"verbose": 3}`,
+		"syntax.json":  `{"verbosity": `,
+	} {
+		assert.Error(t, LoadConfigFile(write(name, content)), name)
+		assert.Equal(t, c, CurrentConfig(), name)
+	}
+	assert.Error(t, LoadConfigFile(filepath.Join(dir, "missing.yaml")))
+}
+
+func TestConfigureFileOutput(t *testing.T) {
+	prev := saveConfig(t)
+
+	// Missing parent directories get created.
+	logFile := filepath.Join(t.TempDir(), "a", "b", "output.log")
+	c := prev
+	c.Severity = "INFO"
+	c.File = logFile
+	c.FileMaxSizeMB = 1
+	c.FileCompress = true
+	c.FileUTC = true
+	c.FileMode = "0640"
+	assert.NoError(t, Configure(c))
+	assert.Equal(t, c, CurrentConfig())
+
+	Info("file output")
+	fi, err := os.Stat(logFile)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+	}
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "file output")
+}
+
+func TestConfigureEncoder(t *testing.T) {
+	prev := saveConfig(t)
+	logFile := filepath.Join(t.TempDir(), "encoder.log")
+	c := prev
+	c.Severity = "INFO"
+	c.File = logFile
+	c.Format = "json"
+	c.Encoder = option.EncoderConfig{
+		TimeKey:       "@timestamp",
+		LevelKey:      "severity",
+		MessageKey:    "message",
+		TimeEncoding:  option.TimeRFC3339Nano,
+		LevelEncoding: option.LevelName,
+	}
+	assert.NoError(t, Configure(c))
+
+	Warning("encoded")
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\{"severity":"WARNING","@timestamp":"\d{4}-\d\d-\d\dT[^"]+","caller":"[^"]+","message":"encoded"\}`, string(r))
+}
@@ -2,15 +2,47 @@ package xlog
 
 import (
 	"context"
-	"sync"
+	"io"
+	"sync/atomic"
 
 	"github.com/go-logr/logr"
+	"github.com/tomhjx/xlog/internal/severity"
 	"github.com/tomhjx/xlog/lib/zapr"
-	"github.com/tomhjx/xlog/option"
 )
 
 type logWriter struct {
 	Logger
+
+	// built is set for loggers which xlog built from its settings.
+	// Their outputs get closed when they are replaced.
+	built bool
+}
+
+// write logs msg with severity s. depth counts the frames of the caller
+// which are to be skipped, like the depth of logr.Logger.WithCallDepth.
+//
+// Sinks which know about severities log s as is. Other sinks only have
+// Info and Error, so warnings become info and fatal messages errors.
+// Info entries always go through logr, which applies the V level of lw.
+func (lw *logWriter) write(s severity.Severity, depth int, err error, msg string, keysAndValues ...interface{}) {
+	if _, ok := lw.GetSink().(zapr.SeverityLogSink); ok && s != severity.InfoLog {
+		// write takes the place of the logr.Logger frame which the
+		// sink expects when it gets called directly.
+		sink := lw.WithCallDepth(depth).GetSink().(zapr.SeverityLogSink)
+		if ss, ok := sink.(zapr.StacktraceSink); ok && s == severity.FatalLog && atomic.LoadUint32(&fatalNoStacks) > 0 {
+			// Exit prints no stacks, not even the one of the caller.
+			sink = ss.WithoutStacktrace()
+		}
+		sink.LogSeverity(s, err, msg, keysAndValues...)
+		return
+	}
+
+	logger := lw.WithCallDepth(depth + 1)
+	if s >= severity.ErrorLog {
+		logger.Error(err, msg, keysAndValues...)
+	} else {
+		logger.Info(msg, keysAndValues...)
+	}
 }
 
 // TODO can be used as a last resort by code that has no means of
@@ -31,7 +63,7 @@ func Background() Logger {
 // LoggerWithValues returns logger.WithValues(...kv) when
 // contextual logging is enabled, otherwise the logger.
 func LoggerWithValues(logger Logger, kv ...interface{}) Logger {
-	if logging.contextualLoggingEnabled {
+	if logging.contextualLoggingEnabled.Load() {
 		return logger.WithValues(kv...)
 	}
 	return logger
@@ -40,7 +72,7 @@ func LoggerWithValues(logger Logger, kv ...interface{}) Logger {
 // LoggerWithName returns logger.WithName(name) when contextual logging is
 // enabled, otherwise the logger.
 func LoggerWithName(logger Logger, name string) Logger {
-	if logging.contextualLoggingEnabled {
+	if logging.contextualLoggingEnabled.Load() {
 		return logger.WithName(name)
 	}
 	return logger
@@ -49,7 +81,7 @@ func LoggerWithName(logger Logger, name string) Logger {
 // NewContext returns logr.NewContext(ctx, logger) when
 // contextual logging is enabled, otherwise ctx.
 func NewContext(ctx context.Context, logger Logger) context.Context {
-	if logging.contextualLoggingEnabled {
+	if logging.contextualLoggingEnabled.Load() {
 		return logr.NewContext(ctx, logger)
 	}
 	return ctx
@@ -58,35 +90,78 @@ func NewContext(ctx context.Context, logger Logger) context.Context {
 // To remove a backing logr implemention, use ClearLogger. Setting an
 // empty logger with SetLogger(logr.Logger{}) does not work.
 //
-// Modifying the logger is not thread-safe and should be done while no other
-// goroutines invoke log calls, usually during program initialization.
+// The logger may be replaced at any time. Log calls which are in flight
+// complete with the previous logger, see Reconfigure.
 func SetLogger(logger logr.Logger) {
-	logging.logger = newLogWriter(logger)
+	logging.replaceLogger(newLogWriter(logger))
 }
 
 // ClearLogger removes a backing Logger implementation if one was set earlier
-// with SetLogger.
+// with SetLogger. The next log call builds a new global logger from the
+// current settings.
 //
-// Modifying the logger is not thread-safe and should be done while no other
-// goroutines invoke log calls, usually during program initialization.
+// Like SetLogger, it may be called at any time.
 func ClearLogger() {
-	logging.logger = nil
+	logging.replaceLogger(nil)
 }
 
 func InitGlobalLogger() {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.initLogger()
+}
 
-	c := option.LogOption{}
-	c.OutputPath = logging.file
-	SetLogger(zapr.New(c))
+// initLogger builds the global logger from the current settings.
+// l.mu is held.
+func (l *loggingT) initLogger() {
+	l.replaceLogger(&logWriter{Logger: zapr.New(l.logOption()), built: true})
 }
 
-var loggingLoggerOnce sync.Once
+// Reconfigure builds a new global logger from the current settings and
+// replaces the previous one, closing its outputs if xlog built it.
+// Programs can log during startup and then switch to the configured
+// outputs, like a file set with SetFile, by calling Reconfigure.
+//
+// Log calls which are in flight while the logger gets replaced complete
+// with the old global logger. Loggers derived from it, like those stored
+// in a context, keep writing to its outputs; a log file which is no
+// longer configured gets closed once they stop using it.
+func Reconfigure() error {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	logger, err := zapr.Build(logging.logOption())
+	if err != nil {
+		return err
+	}
+	logging.replaceLogger(&logWriter{Logger: logger, built: true})
+	return nil
+}
 
+// replaceLogger makes lw the global logger. The outputs of the previous
+// logger are closed if xlog built it.
+func (l *loggingT) replaceLogger(lw *logWriter) {
+	prev := l.logger.Swap(lw)
+	if prev != nil && prev.built {
+		if c, ok := prev.GetSink().(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// GlobalLogger returns the global logger, building it from the current
+// settings on first use.
 func GlobalLogger() *logWriter {
-	if logging.logger == nil {
-		loggingLoggerOnce.Do(InitGlobalLogger)
+	if lw := logging.logger.Load(); lw != nil {
+		return lw
+	}
+
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if lw := logging.logger.Load(); lw == nil {
+		logging.initLogger()
 	}
-	return logging.logger
+	return logging.logger.Load()
 }
 
 func newLogWriter(l Logger) *logWriter {
@@ -97,7 +172,7 @@ func newLogWriter(l Logger) *logWriter {
 // falls back to the program's global logger (a Logger instance or xlog
 // itself).
 func FromContext(ctx context.Context) Logger {
-	if logging.contextualLoggingEnabled {
+	if logging.contextualLoggingEnabled.Load() {
 		if logger, err := logr.FromContext(ctx); err == nil {
 			return logger
 		}
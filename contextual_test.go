@@ -2,7 +2,14 @@ package xlog
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/internal/severity"
 )
 
 func TestContextLogger(t *testing.T) {
@@ -22,3 +29,157 @@ func doSomeMore(ctx context.Context) {
 	logger := FromContext(ctx)
 	logger.Info("hello also from me")
 }
+
+func TestReconfigure(t *testing.T) {
+	saveConfig(t)
+	dir := t.TempDir()
+	startup := filepath.Join(dir, "startup.log")
+	configured := filepath.Join(dir, "configured.log")
+	SetSeverity(severity.InfoLog)
+	SetFile(startup)
+	assert.NoError(t, Reconfigure())
+	Info("during startup")
+	old := GlobalLogger()
+
+	SetFile(configured)
+	assert.NoError(t, Reconfigure())
+	Info("after startup")
+	// The old logger keeps writing to its file.
+	old.Info("stale")
+
+	r, err := os.ReadFile(startup)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "during startup")
+	assert.Contains(t, string(r), "stale")
+	assert.NotContains(t, string(r), "after startup")
+
+	r, err = os.ReadFile(configured)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "after startup")
+	assert.NotContains(t, string(r), "during startup")
+
+	// Bad settings keep the current logger.
+	current := GlobalLogger()
+	SetFile(filepath.Join(configured, "not-a-dir", "x.log"))
+	assert.Error(t, Reconfigure())
+	assert.Same(t, current, GlobalLogger())
+	Info("still configured")
+	r, err = os.ReadFile(configured)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "still configured")
+}
+
+func TestNameVerbosity(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "vname.log")
+	reconciler := Background().WithName("controller").WithName("reconciler")
+
+	// Rules apply to loggers obtained before they were set.
+	assert.NoError(t, SetNameVerbosity("controller.reconciler=4,cache=0"))
+	assert.Error(t, SetNameVerbosity("controller=high"))
+	reconciler.V(4).Info("reconciler v4")
+	reconciler.V(5).Info("reconciler v5")
+	Background().WithName("cache").V(1).Info("cache v1")
+	assert.NoError(t, FlushErr())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "reconciler v4")
+	assert.NotContains(t, string(r), "reconciler v5")
+	assert.NotContains(t, string(r), "cache v1")
+	assert.Equal(t, "controller.reconciler=4,cache=0", CurrentConfig().VName)
+}
+
+func TestVCtx(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	SetVerbosity(1)
+	SwitchContextual(true)
+	logFile := logToFile(t, "vctx.log")
+	logger := Background().WithName("handler").WithValues("request", "r1")
+	ctx := NewContext(context.Background(), logger)
+
+	assert.True(t, VCtx(ctx, 1).Enabled())
+	assert.False(t, VCtx(ctx, 2).Enabled())
+	VCtx(ctx, 1).InfoS("ctx v1")
+	VLogger(logger, 2).InfoS("logger v2")
+
+	// vmodule applies to the call site.
+	assert.NoError(t, SetVModule("contextual_test=2"))
+	VLogger(logger, 2).InfoS("vmodule v2")
+
+	// Rules for the name replace the verbosity and vmodule.
+	assert.NoError(t, SetNameVerbosity("handler=3"))
+	assert.True(t, VCtx(ctx, 3).Enabled())
+	VCtx(ctx, 3).InfoS("vname v3")
+	assert.NoError(t, SetNameVerbosity("handler=0"))
+	assert.False(t, VCtx(ctx, 1).Enabled())
+	assert.True(t, V(1).Enabled())
+	assert.NoError(t, FlushErr())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `"logger":"handler",.*"msg":"ctx v1","request":"r1","v":1`, string(r))
+	assert.NotContains(t, string(r), "logger v2")
+	assert.Regexp(t, `"msg":"vmodule v2","request":"r1","v":2`, string(r))
+	assert.Regexp(t, `"msg":"vname v3","request":"r1","v":3`, string(r))
+}
+
+// vDepthHelper is called by TestVDepth, which is in another file.
+func vDepthHelper(depth int, level Level) Verbose {
+	return VDepth(depth, level)
+}
+
+// TestSwapLoggerConcurrently replaces the global logger while other
+// goroutines are logging. Run it with -race.
+func TestSwapLoggerConcurrently(t *testing.T) {
+	logFile := logToFile(t, "swap.log")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			for j := 0; j < 50; j++ {
+				Info("swap", i, j)
+				InfoS("swap", "goroutine", i, "call", j)
+				V(0).InfoS("swap", "goroutine", i, "call", j)
+				FromContext(ctx).Info("swap", "goroutine", i, "call", j)
+				Flush()
+			}
+		}(i)
+	}
+	for i := 0; i < 40; i++ {
+		switch i % 4 {
+		case 0:
+			SetLogger(logr.Discard())
+		case 1:
+			ClearLogger()
+		case 2:
+			assert.NoError(t, Reconfigure())
+		case 3:
+			c := CurrentConfig()
+			c.Contextual = !c.Contextual
+			assert.NoError(t, Configure(c))
+		}
+	}
+	// The setters change the settings the logger gets built from.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Reconfigure())
+		}()
+		SetFile(logFile)
+		SetFileMaxSizeMB(i + 1)
+		SetFileMaxAgeDay(i)
+		SetFileMaxBackups(i)
+		SetFileCompress(i%2 == 0)
+		SetFileUTC(i%2 == 1)
+		SetFileMode(0o600)
+		SetLogToStderr(false)
+		SetAlsoLogToStderr(false)
+		SetStderrThreshold(severity.FatalLog)
+	}
+	wg.Wait()
+}
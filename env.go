@@ -0,0 +1,145 @@
+package xlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tomhjx/xlog/lib/zapr"
+)
+
+// envSettings maps the environment variables read by ConfigureFromEnv,
+// without their prefix, onto the Config fields.
+var envSettings = []struct {
+	name string
+	set  func(c *Config, value string) error
+}{
+	{"V", func(c *Config, value string) (err error) {
+		c.Verbosity, err = parseLevel(value)
+		return err
+	}},
+	{"VMODULE", func(c *Config, value string) error {
+		if _, err := parseModuleSpec(value); err != nil {
+			return err
+		}
+		c.VModule = value
+		return nil
+	}},
+	{"VNAME", func(c *Config, value string) error {
+		if _, err := zapr.ParseNameLevels(value); err != nil {
+			return err
+		}
+		c.VName = value
+		return nil
+	}},
+	{"SEVERITY", func(c *Config, value string) error {
+		s, err := parseSeverity(value)
+		if err != nil {
+			return err
+		}
+		c.Severity = severityName(s)
+		return nil
+	}},
+	{"STDERR_THRESHOLD", func(c *Config, value string) error {
+		s, err := parseSeverity(value)
+		if err != nil {
+			return err
+		}
+		c.StderrThreshold = severityName(s)
+		return nil
+	}},
+	{"LOGTOSTDERR", func(c *Config, value string) (err error) {
+		c.LogToStderr, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"ALSOLOGTOSTDERR", func(c *Config, value string) (err error) {
+		c.AlsoLogToStderr, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"FILE", func(c *Config, value string) error {
+		c.File = value
+		return nil
+	}},
+	{"FILE_MAX_SIZE_MB", func(c *Config, value string) (err error) {
+		c.FileMaxSizeMB, err = strconv.Atoi(value)
+		return err
+	}},
+	{"FILE_MAX_AGE_DAY", func(c *Config, value string) (err error) {
+		c.FileMaxAgeDay, err = strconv.Atoi(value)
+		return err
+	}},
+	{"FILE_MAX_BACKUPS", func(c *Config, value string) (err error) {
+		c.FileMaxBackups, err = strconv.Atoi(value)
+		return err
+	}},
+	{"FILE_COMPRESS", func(c *Config, value string) (err error) {
+		c.FileCompress, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"FILE_UTC", func(c *Config, value string) (err error) {
+		c.FileUTC, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"FILE_MODE", func(c *Config, value string) error {
+		c.FileMode = value
+		return nil
+	}},
+	{"BACKTRACE_AT", func(c *Config, value string) error {
+		if _, _, err := zapr.ParseTraceLocation(value); err != nil {
+			return err
+		}
+		c.BacktraceAt = value
+		return nil
+	}},
+	{"CONTEXTUAL", func(c *Config, value string) (err error) {
+		c.Contextual, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"FORMAT", func(c *Config, value string) error {
+		c.Format = value
+		return nil
+	}},
+	{"COLOR", func(c *Config, value string) error {
+		c.Color = value
+		return nil
+	}},
+}
+
+// ConfigureFromEnv applies the logging configuration found in environment
+// variables named prefix + "_" + setting, for example XLOG_V=3,
+// XLOG_SEVERITY=warning and XLOG_FILE=/var/log/app.log for the prefix
+// "XLOG". The settings are V, VMODULE, VNAME, SEVERITY,
+// STDERR_THRESHOLD, LOGTOSTDERR, ALSOLOGTOSTDERR, FILE, FILE_MAX_SIZE_MB,
+// FILE_MAX_AGE_DAY, FILE_MAX_BACKUPS, FILE_COMPRESS, FILE_UTC,
+// FILE_MODE, BACKTRACE_AT, CONTEXTUAL, FORMAT and COLOR.
+//
+// Unset variables keep their current value. If any variable cannot be
+// parsed, all such errors are returned and nothing is changed.
+func ConfigureFromEnv(prefix string) error {
+	c, err := readEnv(prefix, CurrentConfig())
+	if err != nil {
+		return err
+	}
+	return Configure(c)
+}
+
+// readEnv overlays the environment variables for prefix onto base.
+func readEnv(prefix string, base Config) (Config, error) {
+	c := base
+	var errs []error
+	for _, s := range envSettings {
+		name := prefix + "_" + s.name
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := s.set(&c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", name, value, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
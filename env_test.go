@@ -0,0 +1,70 @@
+package xlog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureFromEnv(t *testing.T) {
+	saveConfig(t)
+	logFile := filepath.Join(t.TempDir(), "env.log")
+	t.Setenv("XLOG_TEST_V", "3")
+	t.Setenv("XLOG_TEST_VMODULE", "gopher*=4")
+	t.Setenv("XLOG_TEST_VNAME", "cache=2")
+	t.Setenv("XLOG_TEST_SEVERITY", "warning")
+	t.Setenv("XLOG_TEST_STDERR_THRESHOLD", "fatal")
+	t.Setenv("XLOG_TEST_ALSOLOGTOSTDERR", "true")
+	t.Setenv("XLOG_TEST_FILE", logFile)
+	t.Setenv("XLOG_TEST_FILE_MAX_SIZE_MB", "10")
+	t.Setenv("XLOG_TEST_FILE_MAX_AGE_DAY", "7")
+	t.Setenv("XLOG_TEST_FILE_MAX_BACKUPS", "2")
+	t.Setenv("XLOG_TEST_FILE_COMPRESS", "true")
+	t.Setenv("XLOG_TEST_FILE_UTC", "1")
+	t.Setenv("XLOG_TEST_FILE_MODE", "0644")
+	t.Setenv("XLOG_TEST_BACKTRACE_AT", "gopher.go:42")
+	t.Setenv("XLOG_TEST_CONTEXTUAL", "false")
+	t.Setenv("XLOG_TEST_FORMAT", "json")
+	t.Setenv("XLOG_TEST_COLOR", "never")
+
+	assert.NoError(t, ConfigureFromEnv("XLOG_TEST"))
+	want := Config{
+		Verbosity:       3,
+		VModule:         "gopher*=4",
+		VName:           "cache=2",
+		Severity:        "WARNING",
+		StderrThreshold: "FATAL",
+		AlsoLogToStderr: true,
+		File:            logFile,
+		FileMaxSizeMB:   10,
+		FileMaxAgeDay:   7,
+		FileMaxBackups:  2,
+		FileCompress:    true,
+		FileUTC:         true,
+		FileMode:        "0644",
+		BacktraceAt:     "gopher.go:42",
+		Format:          "json",
+		Color:           "never",
+	}
+	assert.Equal(t, want, CurrentConfig())
+
+	// Unset variables keep their value, bad ones are all reported.
+	t.Setenv("XLOG_BAD_V", "high")
+	t.Setenv("XLOG_BAD_VMODULE", "gopher")
+	t.Setenv("XLOG_BAD_SEVERITY", "loud")
+	t.Setenv("XLOG_BAD_CONTEXTUAL", "maybe")
+	err := ConfigureFromEnv("XLOG_BAD")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `XLOG_BAD_V="high"`)
+		assert.Contains(t, err.Error(), `XLOG_BAD_VMODULE="gopher"`)
+		assert.Contains(t, err.Error(), `XLOG_BAD_SEVERITY="loud"`)
+		assert.Contains(t, err.Error(), `XLOG_BAD_CONTEXTUAL="maybe"`)
+	}
+	assert.Equal(t, want, CurrentConfig())
+
+	// Values which parse but are invalid are rejected by Validate.
+	t.Setenv("XLOG_NEG_FILE_MAX_BACKUPS", "-1")
+	assert.Error(t, ConfigureFromEnv("XLOG_NEG"))
+	assert.Equal(t, want, CurrentConfig())
+}
@@ -28,25 +28,33 @@ var (
 
 // FlushAndExit flushes log data for a certain amount of time and then calls
 // os.Exit. Combined with some logging call it provides a replacement for
-// traditional calls like Fatal or Exit.
+// traditional calls like Fatal or Exit. The flush daemon is told to stop
+// first so that it does not race with the final flush; it is not waited
+// for, flushTimeout bounds how long exiting takes.
 func FlushAndExit(flushTimeout time.Duration, exitCode int) {
+	logging.flushD.signalStop()
 	timeoutFlush(flushTimeout)
 	OsExit(exitCode)
 }
 
-// timeoutFlush calls Flush and returns when it completes or after timeout
+// timeoutFlush calls FlushErr and returns when it completes or after timeout
 // elapses, whichever happens first.  This is needed because the hooks invoked
-// by Flush may deadlock when klog.Fatal is called from a hook that holds
-// a lock. Flushing also might take too long.
-func timeoutFlush(timeout time.Duration) {
-	done := make(chan bool, 1)
+// by Flush may deadlock when xlog.Fatal is called from a hook that holds
+// a lock. Flushing also might take too long. Failures are reported on
+// stderr because the log outputs themselves may be what is failing.
+func timeoutFlush(timeout time.Duration) error {
+	done := make(chan error, 1)
 	go func() {
-		Flush() // calls logging.lockAndFlushAll()
-		done <- true
+		done <- FlushErr()
 	}()
 	select {
-	case <-done:
+	case err := <-done:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "xlog: Flush failed:", err)
+		}
+		return err
 	case <-time.After(timeout):
-		fmt.Fprintln(os.Stderr, "klog: Flush took longer than", timeout)
+		fmt.Fprintln(os.Stderr, "xlog: Flush took longer than", timeout)
+		return fmt.Errorf("flush took longer than %s", timeout)
 	}
 }
@@ -0,0 +1,141 @@
+package xlog
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/tomhjx/xlog/lib/zapr"
+)
+
+// InitFlags registers the xlog command line flags on flagset, or on
+// flag.CommandLine if flagset is nil. The flags modify the same settings
+// as the setters in setting.go. It may get called repeatedly for different
+// flagsets, but not twice for the same one.
+//
+// Settings that only take effect when the global logger gets built, like
+// the log file, must be parsed before the first log call.
+//
+// While a log file is set, stderr only gets entries at or above
+// -stderrthreshold, which defaults to ERROR; use -alsologtostderr to
+// keep INFO and WARNING on stderr as well.
+func InitFlags(flagset *flag.FlagSet) {
+	if flagset == nil {
+		flagset = flag.CommandLine
+	}
+
+	flagset.Var(&logging.verbosity, "v", "number for the log level verbosity")
+	flagset.Var(&logging.vmodule, "vmodule", "comma-separated list of pattern=N settings for file- or package-filtered logging")
+	flagset.Var(nameLevelsValue{logging.vname}, "vname", "comma-separated list of name=N settings for the verbosity of loggers by their WithName path, like controller.reconciler=4")
+	flagset.Var(&logging.severity, "severity", "logs at or above this threshold are written (INFO, WARNING, ERROR, FATAL or their numeric value)")
+	flagset.Var(&logging.stderrThreshold, "stderrthreshold",
+		"logs at or above this threshold go to stderr when writing to a file (no effect with -logtostderr or -alsologtostderr)")
+	flagset.BoolVar(&logging.toStderr, "logtostderr", logging.toStderr, "log to standard error instead of the log file")
+	flagset.BoolVar(&logging.alsoToStderr, "alsologtostderr", logging.alsoToStderr, "log to standard error as well as the log file")
+	flagset.StringVar(&logging.file, "log_file", logging.file, "If non-empty, also write logs to this file")
+	flagset.IntVar(&logging.fileMaxSizeMB, "log_file_max_size", logging.fileMaxSizeMB,
+		"Maximum size in megabytes of the log file before it gets rotated. 0 uses the default of 100 MB.")
+	flagset.IntVar(&logging.fileMaxAgeDay, "log_file_max_age", logging.fileMaxAgeDay,
+		"Maximum number of days to retain rotated log files. 0 retains them regardless of their age.")
+	flagset.IntVar(&logging.fileMaxBackups, "log_file_max_backups", logging.fileMaxBackups,
+		"Maximum number of rotated log files to retain. 0 retains all of them.")
+	flagset.BoolVar(&logging.fileCompress, "log_file_compress", logging.fileCompress,
+		"If true, rotated log files are compressed with gzip")
+	flagset.BoolVar(&logging.fileUTC, "log_file_utc", logging.fileUTC,
+		"If true, rotated log files are named by UTC instead of local time")
+	flagset.Var((*fileModeValue)(&logging.fileMode), "log_file_mode",
+		"Octal permission of log files, like 0640. 0 uses the default of 0600.")
+	flagset.Var(traceLocationValue{logging.backtraceAt}, "log_backtrace_at",
+		"when logging hits line file:N, emit a stack trace")
+	flagset.Var(boolValue{&logging.contextualLoggingEnabled}, "contextual",
+		"If true, loggers passed via context or WithName/WithValues are used, otherwise the global logger is")
+}
+
+// nameLevelsValue is the flag.Value for the verbosity of named loggers.
+type nameLevelsValue struct {
+	*zapr.NameLevels
+}
+
+// Set is part of the flag.Value interface.
+func (v nameLevelsValue) Set(value string) error {
+	levels, err := zapr.ParseNameLevels(value)
+	if err != nil {
+		return err
+	}
+	v.NameLevels.Set(levels)
+	return nil
+}
+
+// Type is part of the pflag.Value interface.
+func (v nameLevelsValue) Type() string {
+	return "name=N,..."
+}
+
+// boolValue is the flag.Value for a bool which is read while logging.
+type boolValue struct {
+	*atomic.Bool
+}
+
+// String is part of the flag.Value interface.
+func (b boolValue) String() string {
+	if b.Bool == nil {
+		// flag.isZeroValue calls String on a zero value.
+		return "false"
+	}
+	return strconv.FormatBool(b.Load())
+}
+
+// Set is part of the flag.Value interface.
+func (b boolValue) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	b.Store(v)
+	return nil
+}
+
+// IsBoolFlag allows the flag to be given without a value.
+func (b boolValue) IsBoolFlag() bool {
+	return true
+}
+
+// Type is part of the pflag.Value interface.
+func (b boolValue) Type() string {
+	return "bool"
+}
+
+// traceLocationValue is the flag.Value for the -log_backtrace_at flag.
+type traceLocationValue struct {
+	*zapr.TraceLocation
+}
+
+// Type is part of the pflag.Value interface.
+func (v traceLocationValue) Type() string {
+	return "traceLocation"
+}
+
+// fileModeValue is the flag.Value for octal file permissions.
+type fileModeValue os.FileMode
+
+// String is part of the flag.Value interface.
+func (m *fileModeValue) String() string {
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+// Set is part of the flag.Value interface.
+func (m *fileModeValue) Set(value string) error {
+	v, err := parseFileMode(value)
+	if err != nil {
+		return err
+	}
+	*m = fileModeValue(v)
+	return nil
+}
+
+// Type is part of the pflag.Value interface.
+func (m *fileModeValue) Type() string {
+	return "fileMode"
+}
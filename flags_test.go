@@ -0,0 +1,59 @@
+package xlog
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/internal/severity"
+)
+
+func TestInitFlags(t *testing.T) {
+	saveConfig(t)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	InitFlags(fs)
+	err := fs.Parse([]string{
+		"-v=3",
+		"-vmodule=gopher*=4",
+		"-vname=cache=2",
+		"-severity=warning",
+		"-log_file=/tmp/xlog-flags.log",
+		"-log_file_max_size=10",
+		"-log_file_max_age=7",
+		"-log_file_max_backups=5",
+		"-log_file_compress",
+		"-log_file_utc",
+		"-log_file_mode=0640",
+		"-log_backtrace_at=gopher.go:42",
+		"-contextual=false",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Level(3), logging.verbosity.get())
+	assert.Equal(t, "gopher*=4", logging.vmodule.String())
+	assert.Equal(t, "cache=2", logging.vname.String())
+	assert.Equal(t, severity.WarningLog, logging.severity.get())
+	assert.Equal(t, "/tmp/xlog-flags.log", logging.file)
+	assert.Equal(t, 10, logging.fileMaxSizeMB)
+	assert.Equal(t, 7, logging.fileMaxAgeDay)
+	assert.Equal(t, 5, logging.fileMaxBackups)
+	assert.True(t, logging.fileCompress)
+	assert.True(t, logging.fileUTC)
+	assert.Equal(t, os.FileMode(0o640), logging.fileMode)
+	assert.Equal(t, "gopher.go:42", logging.backtraceAt.String())
+	assert.False(t, logging.contextualLoggingEnabled.Load())
+
+	assert.NoError(t, fs.Parse([]string{"-stderrthreshold=warning", "-logtostderr", "-alsologtostderr"}))
+	assert.Equal(t, severity.WarningLog, logging.stderrThreshold.get())
+	assert.Equal(t, severity.WarningLog, logging.severity.get())
+	assert.True(t, logging.toStderr)
+	assert.True(t, logging.alsoToStderr)
+
+	assert.Error(t, fs.Parse([]string{"-v=high"}))
+	assert.Error(t, fs.Parse([]string{"-vmodule=gopher"}))
+	assert.Error(t, fs.Parse([]string{"-vname=cache"}))
+	assert.Error(t, fs.Parse([]string{"-severity=loud"}))
+	assert.Error(t, fs.Parse([]string{"-log_file_mode=999"}))
+	assert.Error(t, fs.Parse([]string{"-log_backtrace_at=gopher.go"}))
+}
@@ -0,0 +1,112 @@
+package xlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// flushDaemon periodically flushes the log outputs.
+type flushDaemon struct {
+	mu       sync.Mutex
+	flush    func() error
+	stopC    chan struct{}
+	stopDone chan struct{}
+}
+
+// newFlushDaemon returns a new flushDaemon. It is not started yet.
+func newFlushDaemon(flush func() error) *flushDaemon {
+	return &flushDaemon{
+		flush: flush,
+	}
+}
+
+// run starts a goroutine that periodically calls the daemon's flush function.
+// Calling run on an already running daemon restarts it with the new interval.
+// A non-positive interval only stops the daemon.
+func (f *flushDaemon) run(interval time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stopLocked()
+	if interval <= 0 {
+		return
+	}
+
+	f.stopC = make(chan struct{}, 1)
+	f.stopDone = make(chan struct{}, 1)
+
+	go func(stopC, stopDone chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer close(stopDone)
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := f.flush(); err != nil {
+					fmt.Fprintln(os.Stderr, "xlog: Flush failed:", err)
+				}
+			case <-stopC:
+				return
+			}
+		}
+	}(f.stopC, f.stopDone)
+}
+
+// stop stops the running flushDaemon and waits until the daemon has shut down.
+// It is a no-op if the daemon is not running.
+func (f *flushDaemon) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopLocked()
+}
+
+// stopLocked stops the daemon. f.mu is held.
+func (f *flushDaemon) stopLocked() {
+	if f.stopC == nil {
+		return
+	}
+	f.stopC <- struct{}{}
+	<-f.stopDone
+
+	f.stopC = nil
+	f.stopDone = nil
+}
+
+// signalStop tells the running flushDaemon to stop without waiting for
+// it, so that a flush which hangs cannot delay the caller. A flush in
+// progress may still complete. It is a no-op if the daemon is not running.
+func (f *flushDaemon) signalStop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopC == nil {
+		return
+	}
+	// stopC is buffered and only ever gets one value.
+	f.stopC <- struct{}{}
+
+	f.stopC = nil
+	f.stopDone = nil
+}
+
+// isRunning returns true if the flush daemon is running.
+func (f *flushDaemon) isRunning() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stopC != nil
+}
+
+// StartFlushDaemon ensures that the log outputs are synced periodically,
+// so that buffered data never lingers for much longer than interval.
+// Calling it again restarts the daemon with the new interval.
+func StartFlushDaemon(interval time.Duration) {
+	logging.flushD.run(interval)
+}
+
+// StopFlushDaemon stops the flush daemon, if running, and waits until it
+// has shut down. It does not flush; call Flush for that.
+func StopFlushDaemon() {
+	logging.flushD.stop()
+}
@@ -0,0 +1,65 @@
+package xlog
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushDaemon(t *testing.T) {
+	var flushed int32
+	d := newFlushDaemon(func() error {
+		atomic.AddInt32(&flushed, 1)
+		return nil
+	})
+	assert.False(t, d.isRunning())
+
+	d.run(time.Millisecond)
+	assert.True(t, d.isRunning())
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&flushed) >= 3
+	}, time.Second, time.Millisecond)
+
+	d.stop()
+	assert.False(t, d.isRunning())
+	n := atomic.LoadInt32(&flushed)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(&flushed), "flushed after stop")
+
+	// Stopping twice and disabling via the interval are no-ops.
+	d.stop()
+	d.run(0)
+	assert.False(t, d.isRunning())
+}
+
+func TestFlushDaemonSignalStop(t *testing.T) {
+	flushing := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	d := newFlushDaemon(func() error {
+		select {
+		case flushing <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	})
+	d.run(time.Millisecond)
+	<-flushing
+
+	// The flush hangs, but signalStop returns right away.
+	d.signalStop()
+	assert.False(t, d.isRunning())
+	d.signalStop()
+}
+
+func TestStartStopFlushDaemon(t *testing.T) {
+	StartFlushDaemon(time.Millisecond)
+	assert.True(t, logging.flushD.isRunning())
+	StartFlushDaemon(time.Hour)
+	assert.True(t, logging.flushD.isRunning())
+	StopFlushDaemon()
+	assert.False(t, logging.flushD.isRunning())
+}
@@ -3,6 +3,7 @@ package xlog
 import "github.com/tomhjx/xlog/internal/severity"
 
 func init() {
+	logging.flushD = newFlushDaemon(logging.flushAll)
 	SetVerbosity(0)
 	SetSeverity(severity.InfoLog)
 	SwitchContextual(true)
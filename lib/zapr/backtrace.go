@@ -0,0 +1,141 @@
+package zapr
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap/zapcore"
+)
+
+// TraceLocation is a log call site, given by the base name of the source
+// file and the line, like "gopher.go:42". Entries logged there get the
+// stack of the logging goroutine attached. The location may be changed
+// at any time, also while loggers use it.
+//
+// The zero value, like a nil *TraceLocation, matches no call site. It
+// implements option.BacktraceMatcher.
+type TraceLocation struct {
+	loc atomic.Pointer[traceLocation]
+}
+
+type traceLocation struct {
+	file string
+	line int
+}
+
+var errTraceSyntax = errors.New("syntax error: expect file.go:234")
+
+// ParseTraceLocation checks the syntax of a location for TraceLocation.Set.
+func ParseTraceLocation(value string) (file string, line int, err error) {
+	if value == "" {
+		return "", 0, nil
+	}
+	file, lineStr, ok := strings.Cut(value, ":")
+	if !ok || !strings.Contains(file, ".") || strings.ContainsAny(file, `/\`) {
+		return "", 0, errTraceSyntax
+	}
+	line, err = strconv.Atoi(lineStr)
+	if err != nil || line <= 0 {
+		return "", 0, errTraceSyntax
+	}
+	return file, line, nil
+}
+
+// Set changes the location to value, which has the syntax "file.go:234".
+// The empty string removes the location.
+func (t *TraceLocation) Set(value string) error {
+	file, line, err := ParseTraceLocation(value)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		t.loc.Store(nil)
+		return nil
+	}
+	t.loc.Store(&traceLocation{file: file, line: line})
+	return nil
+}
+
+// String returns the location in the syntax of Set.
+func (t *TraceLocation) String() string {
+	if t == nil {
+		return ""
+	}
+	loc := t.loc.Load()
+	if loc == nil {
+		return ""
+	}
+	return loc.file + ":" + strconv.Itoa(loc.line)
+}
+
+// MatchBacktrace reports whether file and line are the location.
+func (t *TraceLocation) MatchBacktrace(file string, line int) bool {
+	if t == nil {
+		return false
+	}
+	loc := t.loc.Load()
+	return loc != nil && loc.line == line && loc.file == filepath.Base(file)
+}
+
+// backtraceCore attaches the stack of the logging goroutine to the
+// entries whose caller matches. It relies on the caller which zap
+// computes for the call depth of the logger, so zap.AddCaller is needed.
+//
+// The caller is only known when writing, so backtraceCore tees the
+// cores itself: the stack gets captured once for all of them, and each
+// core still only receives the entries which its level enables.
+type backtraceCore struct {
+	zapcore.Core // The tee of cores.
+	cores        []zapcore.Core
+	match        option.BacktraceMatcher
+}
+
+func newBacktraceCore(cores []zapcore.Core, match option.BacktraceMatcher) *backtraceCore {
+	return &backtraceCore{Core: zapcore.NewTee(cores...), cores: cores, match: match}
+}
+
+func (c *backtraceCore) With(fields []zapcore.Field) zapcore.Core {
+	cores := make([]zapcore.Core, len(c.cores))
+	for i, core := range c.cores {
+		cores[i] = core.With(fields)
+	}
+	return newBacktraceCore(cores, c.match)
+}
+
+func (c *backtraceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *backtraceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Caller.Defined && c.match.MatchBacktrace(ent.Caller.File, ent.Caller.Line) {
+		ent.Stack = string(stack())
+	}
+	var errs []error
+	for _, core := range c.cores {
+		if core.Enabled(ent.Level) {
+			errs = append(errs, core.Write(ent, fields))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stack returns the stack of the calling goroutine.
+func stack() []byte {
+	n := 10000
+	for {
+		trace := make([]byte, n)
+		nbytes := runtime.Stack(trace, false)
+		if nbytes < len(trace) {
+			return trace[:nbytes]
+		}
+		n *= 2
+	}
+}
@@ -0,0 +1,64 @@
+package zapr
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseTraceLocation(t *testing.T) {
+	file, line, err := ParseTraceLocation("gopher.go:42")
+	assert.NoError(t, err)
+	assert.Equal(t, "gopher.go", file)
+	assert.Equal(t, 42, line)
+
+	for _, value := range []string{"gopher.go", "gopher:42", "gopher.go:x", "gopher.go:0", "pkg/gopher.go:42", "gopher.go:42:1"} {
+		_, _, err := ParseTraceLocation(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBacktraceAt(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	errorCore, errorLogs := observer.New(zapcore.ErrorLevel)
+	trace := &TraceLocation{}
+	logger := NewLogger(zap.New(newBacktraceCore([]zapcore.Core{core, errorCore}, trace), zap.AddCaller()))
+
+	_, _, line, _ := runtime.Caller(0)
+	location := "backtrace_test.go:" + strconv.Itoa(line+4)
+	assert.NoError(t, trace.Set(location))
+	assert.Equal(t, location, trace.String())
+	logger.Info("traced")
+	logger.Info("not traced")
+	assert.NoError(t, trace.Set(""))
+	logger.Info("traced")
+
+	entries := logs.All()
+	if assert.Len(t, entries, 3) {
+		assert.Contains(t, entries[0].Stack, "goroutine ")
+		assert.Contains(t, entries[0].Stack, "TestBacktraceAt")
+		assert.Empty(t, entries[1].Stack)
+		assert.Empty(t, entries[2].Stack)
+	}
+	// Each core only gets the entries its level enables.
+	assert.Zero(t, errorLogs.Len())
+
+	logger = logger.WithValues("request", "r1")
+	_, _, line, _ = runtime.Caller(0)
+	assert.NoError(t, trace.Set("backtrace_test.go:"+strconv.Itoa(line+2)))
+	logger.Error(nil, "traced error")
+	if assert.Equal(t, 1, errorLogs.Len()) {
+		entry := errorLogs.All()[0]
+		assert.Contains(t, entry.Stack, "TestBacktraceAt")
+		assert.Equal(t, "r1", entry.ContextMap()["request"])
+		// The stack was captured once for both cores.
+		last := logs.All()[logs.Len()-1]
+		assert.Same(t, unsafe.StringData(entry.Stack), unsafe.StringData(last.Stack))
+	}
+}
@@ -1,50 +1,405 @@
 package zapr
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/tomhjx/xlog/internal/severity"
 	"github.com/tomhjx/xlog/option"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
-type lumberjackSink struct {
+// sinkScheme is the zap sink scheme used for rotated log files. zap
+// already owns the "file" scheme, so the lumberjack sink needs its own.
+const sinkScheme = "lumberjack"
+
+func init() {
+	if err := zap.RegisterSink(sinkScheme, newLumberjackSink); err != nil {
+		panic(err)
+	}
+}
+
+// lumberjackFile is a rotated log file shared by all sinks opened for
+// the same URL. lumberjack starts a goroutine for removing old files
+// which it never stops, so the Logger is kept for the lifetime of the
+// process instead of creating one per sink. Its file gets closed
+// closeDelay after the last sink was closed and reopened by the next
+// write.
+type lumberjackFile struct {
 	*lumberjack.Logger
+
+	// refs counts the open sinks of the file and timer closes it once
+	// there are none. Both are protected by files.
+	refs  int
+	timer *time.Timer
 }
 
-// Sync implements zap.Sink. The remaining methods are implemented
-// by the embedded *lumberjack.Logger.
-func (lumberjackSink) Sync() error { return nil }
+// closeDelay is how long a file stays open after its last sink was
+// closed. Loggers derived from a replaced logger may still write to it,
+// each such write keeps it open for another closeDelay.
+var closeDelay = 10 * time.Second
 
-func New(op option.LogOption) logr.Logger {
+// files holds every lumberjackFile by sink URL.
+var files = struct {
+	sync.Mutex
+	m map[string]*lumberjackFile
+}{m: map[string]*lumberjackFile{}}
+
+// sync commits the data written to f to stable storage. lumberjack does
+// not buffer writes and keeps its file handle private, but fsync
+// applies to the file and not to the handle, so a new one will do.
+func (f *lumberjackFile) sync() error {
+	h, err := os.OpenFile(f.Filename, os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Nothing was written yet.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = h.Sync()
+	if closeErr := h.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// closeLater closes f after closeDelay unless it gets a sink again in
+// the meantime. files is held.
+func (f *lumberjackFile) closeLater() {
+	if f.timer != nil {
+		f.timer.Reset(closeDelay)
+		return
+	}
+	f.timer = time.AfterFunc(closeDelay, func() {
+		files.Lock()
+		defer files.Unlock()
+		if f.refs == 0 {
+			f.Close()
+		}
+	})
+}
+
+// openFiles returns the files which have open sinks.
+func openFiles() []*lumberjackFile {
+	files.Lock()
+	defer files.Unlock()
+
+	open := make([]*lumberjackFile, 0, len(files.m))
+	for _, f := range files.m {
+		if f.refs > 0 {
+			open = append(open, f)
+		}
+	}
+	return open
+}
+
+// lumberjackSink is a zap.Sink writing to a lumberjackFile.
+type lumberjackSink struct {
+	file   *lumberjackFile
+	closed atomic.Bool
+}
+
+// Write implements zap.Sink. Writes after Close still reach the file,
+// so that loggers derived from a logger which got replaced keep working.
+func (s *lumberjackSink) Write(p []byte) (int, error) {
+	if s.closed.Load() {
+		files.Lock()
+		defer files.Unlock()
+		if s.file.refs == 0 {
+			s.file.closeLater()
+		}
+	}
+	return s.file.Write(p)
+}
+
+// Sync implements zap.Sink.
+func (s *lumberjackSink) Sync() error {
+	return s.file.sync()
+}
+
+// Close implements zap.Sink. The file is closed some time after its
+// last sink, see closeDelay.
+func (s *lumberjackSink) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+
+	files.Lock()
+	defer files.Unlock()
+	if s.file.refs--; s.file.refs == 0 {
+		s.file.closeLater()
+	}
+	return nil
+}
+
+func newLumberjackSink(u *url.URL) (zap.Sink, error) {
+	op, err := sinkOption(u)
+	if err != nil {
+		return nil, err
+	}
 
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
-		enc.AppendString(severity.Flag(LevelSeverity(l)))
+	dirMode := op.DirMode
+	if dirMode == 0 {
+		dirMode = 0o755
+	}
+	if err := os.MkdirAll(filepath.Dir(op.OutputPath), dirMode); err != nil {
+		return nil, err
+	}
+	if op.FileMode != 0 {
+		// lumberjack creates files with 0600 but keeps the mode of
+		// an existing file when rotating, so creating the file up
+		// front is enough to apply FileMode to all of them.
+		f, err := os.OpenFile(op.OutputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, op.FileMode)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		if err := os.Chmod(op.OutputPath, op.FileMode); err != nil {
+			return nil, err
+		}
 	}
 
-	zap.RegisterSink("file", func(u *url.URL) (zap.Sink, error) {
-		return lumberjackSink{&lumberjack.Logger{
-			Filename:   u.Opaque,
+	files.Lock()
+	defer files.Unlock()
+	f := files.m[u.String()]
+	if f == nil {
+		f = &lumberjackFile{Logger: &lumberjack.Logger{
+			Filename:   op.OutputPath,
 			MaxSize:    op.MaxSizeMB,
 			MaxAge:     op.MaxAgeDay,
 			MaxBackups: op.MaxBackups,
-			LocalTime:  true,
-		}}, nil
-	})
+			LocalTime:  !op.UTC,
+			Compress:   op.Compress,
+		}}
+		files.m[u.String()] = f
+	}
+	f.refs++
+	return &lumberjackSink{file: f}, nil
+}
 
-	zc := zap.NewProductionConfig()
-	zc.EncoderConfig = encoderConfig
-	if op.OutputPath != "" {
-		zc.OutputPaths = append(zc.OutputPaths, op.OutputPath)
+// sinkURL returns the zap output path which opens a lumberjack sink
+// for the file described by op.
+func sinkURL(op option.LogOption) string {
+	q := url.Values{}
+	q.Set("maxsize", strconv.Itoa(op.MaxSizeMB))
+	q.Set("maxage", strconv.Itoa(op.MaxAgeDay))
+	q.Set("maxbackups", strconv.Itoa(op.MaxBackups))
+	q.Set("compress", strconv.FormatBool(op.Compress))
+	q.Set("utc", strconv.FormatBool(op.UTC))
+	q.Set("mode", strconv.FormatUint(uint64(op.FileMode), 8))
+	q.Set("dirmode", strconv.FormatUint(uint64(op.DirMode), 8))
+	u := url.URL{Scheme: sinkScheme, Path: op.OutputPath, RawQuery: q.Encode()}
+	if !filepath.IsAbs(op.OutputPath) {
+		// A path without leading slash would be taken for a host.
+		u.Path, u.Opaque = "", op.OutputPath
 	}
-	zl, err := zc.Build()
+	return u.String()
+}
+
+// sinkOption is the reverse of sinkURL.
+func sinkOption(u *url.URL) (option.LogOption, error) {
+	op := option.LogOption{OutputPath: u.Path}
+	if op.OutputPath == "" {
+		// Relative paths end up as opaque URLs.
+		op.OutputPath = u.Opaque
+	}
+
+	q := u.Query()
+	var errs []error
+	parse := func(key string, parse func(string) error) {
+		if v := q.Get(key); v != "" {
+			if err := parse(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	parseInt := func(p *int) func(string) error {
+		return func(v string) (err error) {
+			*p, err = strconv.Atoi(v)
+			return err
+		}
+	}
+	parseBool := func(p *bool) func(string) error {
+		return func(v string) (err error) {
+			*p, err = strconv.ParseBool(v)
+			return err
+		}
+	}
+	parseMode := func(p *os.FileMode) func(string) error {
+		return func(v string) error {
+			m, err := strconv.ParseUint(v, 8, 32)
+			*p = os.FileMode(m)
+			return err
+		}
+	}
+	parse("maxsize", parseInt(&op.MaxSizeMB))
+	parse("maxage", parseInt(&op.MaxAgeDay))
+	parse("maxbackups", parseInt(&op.MaxBackups))
+	parse("compress", parseBool(&op.Compress))
+	parse("utc", parseBool(&op.UTC))
+	parse("mode", parseMode(&op.FileMode))
+	parse("dirmode", parseMode(&op.DirMode))
+	return op, errors.Join(errs...)
+}
+
+// SyncSinks commits the data written to every open file sink to stable
+// storage. All sinks are synced even if some of them fail.
+func SyncSinks() error {
+	var errs []error
+	for _, f := range openFiles() {
+		if err := f.sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteSinks writes p unchanged to every open file sink, bypassing the
+// encoder. It is meant for data such as stack dumps which do not fit
+// into a log entry.
+func WriteSinks(p []byte) error {
+	var errs []error
+	for _, f := range openFiles() {
+		if _, err := f.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// New creates a logr.Logger as described by op. It exits the program
+// if op is invalid; use Build to handle such errors.
+func New(op option.LogOption) logr.Logger {
+	l, err := Build(op)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return NewLogger(zl)
+	return l
+}
+
+// newEncoder returns the encoder for op.Encoding, for stderr or the log
+// file.
+func newEncoder(op option.LogOption, stderr bool) (zapcore.Encoder, error) {
+	switch op.Color {
+	case "", option.ColorAuto, option.ColorAlways, option.ColorNever:
+	default:
+		return nil, fmt.Errorf("unknown color mode %q", op.Color)
+	}
+	encoderConfig, err := NewJSONEncoderConfig(op.EncoderConfig)
+	if err != nil {
+		return nil, err
+	}
+	terminal := stderr && isTerminal(os.Stderr)
+	encoding := op.Encoding
+	if encoding == "" && terminal {
+		encoding = option.EncodingConsole
+	}
+
+	switch encoding {
+	case "", option.EncodingJSON:
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	case option.EncodingText:
+		return NewTextEncoder(), nil
+	case option.EncodingLogfmt:
+		return NewLogfmtEncoder(), nil
+	case option.EncodingConsole:
+		return NewConsoleEncoder(stderr && useColor(op.Color, terminal)), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", op.Encoding)
+	}
+}
+
+// bothEnabled enables the levels which both of its LevelEnablers enable.
+type bothEnabled [2]zapcore.LevelEnabler
+
+func (b bothEnabled) Enabled(l zapcore.Level) bool {
+	return b[0].Enabled(l) && b[1].Enabled(l)
+}
+
+// VerbosityKey is the key of the V level of info entries written by the
+// loggers from Build.
+const VerbosityKey = "v"
+
+// fatalHook leaves terminating the program after a fatal message to the
+// caller, zap would call os.Exit right away.
+type fatalHook struct{}
+
+func (fatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
+// Build creates a logr.Logger which writes to stderr and, if set, to the
+// rotated file op.OutputPath. Each output has its own level, so that
+// stderr may receive fewer entries than the file. The LogSink of the
+// logger implements io.Closer; closing it releases the file.
+func Build(op option.LogOption) (logr.Logger, error) {
+	level := op.Level
+	if level == nil {
+		level = zap.InfoLevel
+	}
+	type output struct {
+		path    string
+		level   zapcore.LevelEnabler
+		encoder zapcore.Encoder
+	}
+	stderrEncoder, err := newEncoder(op, true)
+	if err != nil {
+		return logr.Logger{}, err
+	}
+	outputs := []output{{"stderr", level, stderrEncoder}}
+	if op.StderrLevel != nil {
+		outputs[0].level = bothEnabled{level, op.StderrLevel}
+	}
+	if op.OutputPath != "" {
+		fileEncoder, err := newEncoder(op, false)
+		if err != nil {
+			return logr.Logger{}, err
+		}
+		outputs = append(outputs, output{sinkURL(op), level, fileEncoder})
+	}
+
+	// The same setup as zap.NewProductionConfig, but with a core per
+	// output and with access to the sinks for closing them.
+	var cores []zapcore.Core
+	var closers []func()
+	closeSinks := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	for _, o := range outputs {
+		sink, closeSink, err := zap.Open(o.path)
+		if err != nil {
+			closeSinks()
+			return logr.Logger{}, err
+		}
+		closers = append(closers, closeSink)
+		cores = append(cores, zapcore.NewCore(o.encoder, sink, o.level))
+	}
+	core := zapcore.NewTee(cores...)
+	if op.BacktraceAt != nil {
+		core = newBacktraceCore(cores, op.BacktraceAt)
+	}
+	core = zapcore.NewSamplerWithOptions(core, time.Second, 100, 100)
+	zl := zap.New(core,
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+		zap.AddCaller(),
+		zap.AddStacktrace(zap.ErrorLevel),
+		zap.WithFatalHook(fatalHook{}),
+	)
+	return NewLoggerWithOptions(zl,
+		closeWith(closeSinks),
+		NameVerbosity(op.NameLevels),
+		LogInfoLevel(VerbosityKey),
+	), nil
 }
@@ -0,0 +1,100 @@
+package zapr
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/option"
+)
+
+func TestSinkURL(t *testing.T) {
+	for _, op := range []option.LogOption{
+		{OutputPath: "/var/log/app.log"},
+		{OutputPath: "logs/app.log", MaxSizeMB: 10, MaxAgeDay: 7, MaxBackups: 3},
+		{OutputPath: "/var/log/app.log", Compress: true, UTC: true, FileMode: 0o640, DirMode: 0o750},
+	} {
+		u, err := url.Parse(sinkURL(op))
+		if assert.NoError(t, err) {
+			got, err := sinkOption(u)
+			assert.NoError(t, err)
+			assert.Equal(t, op, got)
+		}
+	}
+
+	u, err := url.Parse(sinkScheme + ":/tmp/app.log?maxsize=big&mode=999")
+	if assert.NoError(t, err) {
+		_, err = sinkOption(u)
+		assert.Error(t, err)
+	}
+}
+
+func TestLumberjackSinkSync(t *testing.T) {
+	u, err := url.Parse(sinkURL(option.LogOption{OutputPath: filepath.Join(t.TempDir(), "sync.log")}))
+	assert.NoError(t, err)
+	sink, err := newLumberjackSink(u)
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	assert.NoError(t, sink.Sync())
+	_, err = sink.Write([]byte("before\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Sync())
+	assert.NoError(t, sink.(*lumberjackSink).file.Rotate())
+	assert.NoError(t, sink.Sync())
+}
+
+func TestLumberjackSinkClose(t *testing.T) {
+	defer func(d time.Duration) { closeDelay = d }(closeDelay)
+	closeDelay = 10 * time.Millisecond
+	logFile := filepath.Join(t.TempDir(), "close.log")
+	u, err := url.Parse(sinkURL(option.LogOption{OutputPath: logFile}))
+	assert.NoError(t, err)
+	before := runtime.NumGoroutine()
+	sink, err := newLumberjackSink(u)
+	assert.NoError(t, err)
+
+	_, err = sink.Write([]byte("open\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Close())
+	assert.NoError(t, sink.Close())
+	// Writes after Close still reach the file.
+	_, err = sink.Write([]byte("closed\n"))
+	assert.NoError(t, err)
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "open\nclosed\n", string(r))
+
+	// The file gets closed once nothing writes to it anymore.
+	isOpen := func() bool {
+		fds, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("no /proc/self/fd:", err)
+		}
+		for _, fd := range fds {
+			if target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); target == logFile {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 1000 && isOpen(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, isOpen())
+
+	// Sinks for the same file share it, so reopening it does not start
+	// another goroutine for removing old files.
+	for i := 0; i < 5; i++ {
+		sink, err := newLumberjackSink(u)
+		assert.NoError(t, err)
+		_, err = sink.Write([]byte("reopened\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Close())
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+1)
+}
@@ -0,0 +1,177 @@
+package zapr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The widths of the columns of the console encoding which are padded.
+const (
+	consoleCallerWidth  = 24
+	consoleMessageWidth = 40
+)
+
+// ANSI escape sequences of the console encoding.
+const (
+	colorReset   = "\x1b[0m"
+	colorFaint   = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+)
+
+// consoleEncoder writes entries in aligned columns for humans:
+//
+//	00:15:15.525 I controller_utils.go:116  controller: Pod status updated  pod=kubedns status=ready
+//
+// The columns are the time, the severity character, the short caller and
+// the message, prefixed with the logger name. Key/value pairs are only
+// quoted where needed, multi-line values are indented on the following
+// lines. With colors, the severity character is colored and the keys are
+// faint.
+type consoleEncoder struct {
+	*fieldList
+	color bool
+}
+
+// NewConsoleEncoder returns a zapcore.Encoder for the console encoding,
+// which uses ANSI colors if color is true.
+func NewConsoleEncoder(color bool) zapcore.Encoder {
+	return &consoleEncoder{fieldList: newFieldList(), color: color}
+}
+
+func (enc *consoleEncoder) Clone() zapcore.Encoder {
+	return &consoleEncoder{fieldList: enc.clone(), color: enc.color}
+}
+
+func (enc *consoleEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	buf.AppendTime(ent.Time, "15:04:05.000")
+	buf.AppendByte(' ')
+	enc.colored(buf, levelColor(ent.Level), string(levelChar(ent.Level)))
+	buf.AppendByte(' ')
+
+	caller := "???"
+	if ent.Caller.Defined {
+		caller = filepath.Base(ent.Caller.File) + ":" + strconv.Itoa(ent.Caller.Line)
+	}
+	buf.AppendString(caller)
+	pad(buf, consoleCallerWidth-utf8.RuneCountInString(caller))
+
+	msg := ent.Message
+	if ent.LoggerName != "" {
+		msg = ent.LoggerName + ": " + msg
+	}
+	buf.AppendString(msg)
+
+	kvs := enc.entryFields(fields)
+	if len(kvs) > 0 {
+		pad(buf, consoleMessageWidth-utf8.RuneCountInString(msg))
+	}
+	for _, kv := range kvs {
+		buf.AppendByte(' ')
+		if enc.color {
+			buf.AppendString(colorFaint)
+		}
+		writeLogfmtKey(buf, kv.key)
+		buf.AppendByte('=')
+		if enc.color {
+			buf.AppendString(colorReset)
+		}
+		s, raw := consoleValue(kv.value)
+		switch {
+		case strings.IndexByte(s, '\n') >= 0:
+			writeTextString(buf, s)
+		case raw:
+			buf.AppendString(s)
+		default:
+			writeLogfmtString(buf, s)
+		}
+	}
+	if ent.Stack != "" {
+		buf.AppendByte('\n')
+		buf.AppendString(strings.TrimSuffix(ent.Stack, "\n"))
+	}
+	buf.AppendByte('\n')
+	return buf, nil
+}
+
+// consoleValue returns the unquoted string for value. Structured values
+// are JSON-encoded like in the text format and may be written as they
+// are, which raw reports.
+func consoleValue(value interface{}) (s string, raw bool) {
+	switch value.(type) {
+	case nil, string, []byte, time.Time, error, fmt.Stringer:
+		return logfmtValue(value), false
+	}
+	return formatOther(value)
+}
+
+// colored appends s to buf, in color if colors are enabled.
+func (enc *consoleEncoder) colored(buf *buffer.Buffer, color, s string) {
+	if !enc.color {
+		buf.AppendString(s)
+		return
+	}
+	buf.AppendString(color)
+	buf.AppendString(s)
+	buf.AppendString(colorReset)
+}
+
+// levelColor returns the color of the severity character for l. V levels
+// are set apart from plain info entries.
+func levelColor(l zapcore.Level) string {
+	if l < zapcore.InfoLevel {
+		return colorMagenta
+	}
+	switch LevelSeverity(l) {
+	case severity.InfoLog:
+		return colorBlue
+	case severity.WarningLog:
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+// pad appends n spaces to buf, at least one.
+func pad(buf *buffer.Buffer, n int) {
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		buf.AppendByte(' ')
+	}
+}
+
+// isTerminal reports whether f is a terminal. It is a variable for the
+// tests.
+var isTerminal = func(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether the console encoding writes colors to stderr
+// in mode, one of the option.Color modes.
+func useColor(mode string, terminal bool) bool {
+	switch mode {
+	case option.ColorAlways:
+		return true
+	case option.ColorNever:
+		return false
+	default:
+		return terminal && os.Getenv("NO_COLOR") == ""
+	}
+}
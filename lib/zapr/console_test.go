@@ -0,0 +1,91 @@
+package zapr
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConsoleEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2023, time.October, 25, 0, 15, 15, 525108000, time.Local),
+		LoggerName: "controller",
+		Message:    "Pod status updated",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/controller_utils.go", 116, true),
+	}
+	fields := []zapcore.Field{
+		zap.String("status", "not ready"),
+		zap.Object("owner", testObject{"dns", 2}),
+		zap.String("lines", "line 1\nline 2"),
+		zap.Any("tags", []string{"a", "b"}),
+		zap.String("a=b c", "x"),
+	}
+
+	enc := NewConsoleEncoder(false)
+	enc.AddString("pod", "kubedns")
+	buf, err := enc.EncodeEntry(ent, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "00:15:15.525 W controller_utils.go:116 controller: Pod status updated          "+
+		` pod=kubedns status="not ready" owner.name=dns owner.size=2 lines=<`+"\n\tline 1\n\tline 2\n >"+
+		` tags=["a","b"] a_b_c=x`+"\n", buf.String())
+
+	buf, err = enc.EncodeEntry(zapcore.Entry{Level: zapcore.DebugLevel, Time: ent.Time, Message: "next", Stack: "goroutine 1\n"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "00:15:15.525 I ???                     next                                     pod=kubedns\ngoroutine 1\n", buf.String())
+
+	buf, err = NewConsoleEncoder(true).EncodeEntry(ent, fields[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, "00:15:15.525 \x1b[33mW\x1b[0m controller_utils.go:116 controller: Pod status updated          "+
+		" \x1b[2mstatus=\x1b[0m\"not ready\"\n", buf.String())
+}
+
+func TestNewEncoder(t *testing.T) {
+	defer func(orig func(*os.File) bool) { isTerminal = orig }(isTerminal)
+	terminal := false
+	isTerminal = func(*os.File) bool { return terminal }
+
+	for name, tc := range map[string]struct {
+		terminal  bool
+		noColor   string
+		op        option.LogOption
+		stderr    bool
+		wantColor bool
+		wantJSON  bool
+	}{
+		"no terminal":         {stderr: true, wantJSON: true},
+		"terminal":            {terminal: true, stderr: true, wantColor: true},
+		"terminal file":       {terminal: true, wantJSON: true},
+		"explicit json":       {terminal: true, stderr: true, op: option.LogOption{Encoding: option.EncodingJSON}, wantJSON: true},
+		"NO_COLOR":            {terminal: true, noColor: "1", stderr: true},
+		"color never":         {terminal: true, stderr: true, op: option.LogOption{Color: option.ColorNever}},
+		"color always":        {stderr: true, op: option.LogOption{Encoding: option.EncodingConsole, Color: option.ColorAlways}, wantColor: true},
+		"color always file":   {op: option.LogOption{Encoding: option.EncodingConsole, Color: option.ColorAlways}},
+		"console no terminal": {stderr: true, op: option.LogOption{Encoding: option.EncodingConsole}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			terminal = tc.terminal
+			t.Setenv("NO_COLOR", tc.noColor)
+			enc, err := newEncoder(tc.op, tc.stderr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if tc.wantJSON {
+				_, ok := enc.(*consoleEncoder)
+				assert.False(t, ok)
+				return
+			}
+			if assert.IsType(t, &consoleEncoder{}, enc) {
+				assert.Equal(t, tc.wantColor, enc.(*consoleEncoder).color)
+			}
+		})
+	}
+
+	_, err := newEncoder(option.LogOption{Color: "blue"}, true)
+	assert.Error(t, err)
+}
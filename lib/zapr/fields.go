@@ -0,0 +1,125 @@
+package zapr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// bufferPool provides the buffers of the encoders in this package.
+var bufferPool = buffer.NewPool()
+
+// formatOther formats the values of fields which are neither strings nor
+// have a String or Error method. Booleans and numbers are formatted with
+// %v. Other values, like slices, maps and structs, are JSON-encoded so
+// that they keep their structure; ok is false for those which can't be
+// encoded and are formatted with %+v instead.
+func formatOther(value interface{}) (s string, ok bool) {
+	switch value.(type) {
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return fmt.Sprint(value), true
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return fmt.Sprintf("%+v", value), false
+	}
+	return string(bytes.TrimSuffix(b.Bytes(), []byte{'\n'})), true
+}
+
+// keyValue is one field of an entry.
+type keyValue struct {
+	key   string
+	value interface{}
+}
+
+// fieldList is a zapcore.ObjectEncoder which collects fields in the order
+// in which they are added, for the encoders which write key/value pairs
+// instead of JSON. Nested objects are flattened, their keys are joined
+// with dots. Arrays become []interface{}.
+type fieldList struct {
+	// prefix is prepended to the keys, it is set for nested objects
+	// and namespaces.
+	prefix string
+	kvs    *[]keyValue
+}
+
+func newFieldList() *fieldList {
+	return &fieldList{kvs: &[]keyValue{}}
+}
+
+// clone returns a copy of f which can be modified independently.
+func (f *fieldList) clone() *fieldList {
+	kvs := make([]keyValue, len(*f.kvs))
+	copy(kvs, *f.kvs)
+	return &fieldList{prefix: f.prefix, kvs: &kvs}
+}
+
+func (f *fieldList) add(key string, value interface{}) {
+	*f.kvs = append(*f.kvs, keyValue{f.prefix + key, value})
+}
+
+func (f *fieldList) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	// MapObjectEncoder knows how to turn arrays into slices.
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddArray(key, marshaler)
+	f.add(key, m.Fields[key])
+	return err
+}
+
+func (f *fieldList) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	return marshaler.MarshalLogObject(&fieldList{prefix: f.prefix + key + ".", kvs: f.kvs})
+}
+
+func (f *fieldList) AddBinary(key string, value []byte) { f.add(key, value) }
+func (f *fieldList) AddByteString(key string, value []byte) {
+	f.add(key, string(value))
+}
+func (f *fieldList) AddBool(key string, value bool)              { f.add(key, value) }
+func (f *fieldList) AddComplex128(key string, value complex128)  { f.add(key, value) }
+func (f *fieldList) AddComplex64(key string, value complex64)    { f.add(key, value) }
+func (f *fieldList) AddDuration(key string, value time.Duration) { f.add(key, value) }
+func (f *fieldList) AddFloat64(key string, value float64)        { f.add(key, value) }
+func (f *fieldList) AddFloat32(key string, value float32)        { f.add(key, value) }
+func (f *fieldList) AddInt(key string, value int)                { f.add(key, value) }
+func (f *fieldList) AddInt64(key string, value int64)            { f.add(key, value) }
+func (f *fieldList) AddInt32(key string, value int32)            { f.add(key, value) }
+func (f *fieldList) AddInt16(key string, value int16)            { f.add(key, value) }
+func (f *fieldList) AddInt8(key string, value int8)              { f.add(key, value) }
+func (f *fieldList) AddString(key, value string)                 { f.add(key, value) }
+func (f *fieldList) AddTime(key string, value time.Time)         { f.add(key, value) }
+func (f *fieldList) AddUint(key string, value uint)              { f.add(key, value) }
+func (f *fieldList) AddUint64(key string, value uint64)          { f.add(key, value) }
+func (f *fieldList) AddUint32(key string, value uint32)          { f.add(key, value) }
+func (f *fieldList) AddUint16(key string, value uint16)          { f.add(key, value) }
+func (f *fieldList) AddUint8(key string, value uint8)            { f.add(key, value) }
+func (f *fieldList) AddUintptr(key string, value uintptr)        { f.add(key, value) }
+
+func (f *fieldList) AddReflected(key string, value interface{}) error {
+	f.add(key, value)
+	return nil
+}
+
+func (f *fieldList) OpenNamespace(key string) {
+	f.prefix += key + "."
+}
+
+var _ zapcore.ObjectEncoder = &fieldList{}
+
+// entryFields returns the context fields of f followed by fields.
+func (f *fieldList) entryFields(fields []zapcore.Field) []keyValue {
+	if len(fields) == 0 {
+		return *f.kvs
+	}
+	all := f.clone()
+	for _, field := range fields {
+		field.AddTo(all)
+	}
+	return *all.kvs
+}
@@ -0,0 +1,100 @@
+package zapr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewJSONEncoderConfig returns the configuration of the JSON encoder for
+// c. Unknown encodings are an error, as are time encodings which are
+// neither one of the option.Time constants nor a layout.
+func NewJSONEncoderConfig(c option.EncoderConfig) (zapcore.EncoderConfig, error) {
+	ec := zap.NewProductionEncoderConfig()
+	for _, k := range []struct {
+		key   *string
+		value string
+	}{
+		{&ec.TimeKey, c.TimeKey},
+		{&ec.LevelKey, c.LevelKey},
+		{&ec.NameKey, c.NameKey},
+		{&ec.CallerKey, c.CallerKey},
+		{&ec.MessageKey, c.MessageKey},
+		{&ec.StacktraceKey, c.StacktraceKey},
+	} {
+		if k.value != "" {
+			*k.key = k.value
+		}
+	}
+
+	switch c.TimeEncoding {
+	case "", option.TimeEpoch:
+		ec.EncodeTime = zapcore.EpochTimeEncoder
+	case option.TimeEpochMillis:
+		ec.EncodeTime = zapcore.EpochMillisTimeEncoder
+	case option.TimeEpochNanos:
+		ec.EncodeTime = zapcore.EpochNanosTimeEncoder
+	case option.TimeRFC3339:
+		ec.EncodeTime = zapcore.RFC3339TimeEncoder
+	case option.TimeRFC3339Nano:
+		ec.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	default:
+		if !isTimeLayout(c.TimeEncoding) {
+			return ec, fmt.Errorf("unknown time encoding %q", c.TimeEncoding)
+		}
+		ec.EncodeTime = zapcore.TimeEncoderOfLayout(c.TimeEncoding)
+	}
+
+	switch c.DurationEncoding {
+	case "", option.DurationSeconds:
+		ec.EncodeDuration = zapcore.SecondsDurationEncoder
+	case option.DurationMillis:
+		ec.EncodeDuration = zapcore.MillisDurationEncoder
+	case option.DurationNanos:
+		ec.EncodeDuration = zapcore.NanosDurationEncoder
+	case option.DurationString:
+		ec.EncodeDuration = zapcore.StringDurationEncoder
+	default:
+		return ec, fmt.Errorf("unknown duration encoding %q", c.DurationEncoding)
+	}
+
+	switch c.CallerEncoding {
+	case "", option.CallerShort:
+		ec.EncodeCaller = zapcore.ShortCallerEncoder
+	case option.CallerFull:
+		ec.EncodeCaller = zapcore.FullCallerEncoder
+	default:
+		return ec, fmt.Errorf("unknown caller encoding %q", c.CallerEncoding)
+	}
+
+	switch c.LevelEncoding {
+	case "", option.LevelChar:
+		ec.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(severity.Flag(LevelSeverity(l)))
+		}
+	case option.LevelName:
+		ec.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(severity.Name[LevelSeverity(l)])
+		}
+	case option.LevelLowercase:
+		ec.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(strings.ToLower(severity.Name[LevelSeverity(l)]))
+		}
+	default:
+		return ec, fmt.Errorf("unknown level encoding %q", c.LevelEncoding)
+	}
+	return ec, nil
+}
+
+// isTimeLayout reports whether layout contains at least one element of
+// the reference time, so that misspelled encodings aren't taken for a
+// constant string.
+func isTimeLayout(layout string) bool {
+	t := time.Date(2001, time.February, 3, 4, 5, 6, 7, time.UTC)
+	return t.Format(layout) != layout
+}
@@ -0,0 +1,80 @@
+package zapr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewJSONEncoderConfig(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2023, time.October, 25, 0, 15, 15, 525108000, time.UTC),
+		LoggerName: "controller",
+		Message:    "Pod status updated",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/controller_utils.go", 116, true),
+	}
+	fields := []zapcore.Field{zap.Duration("after", 1500*time.Millisecond)}
+
+	for name, tc := range map[string]struct {
+		config  option.EncoderConfig
+		want    string
+		wantErr bool
+	}{
+		"default": {
+			want: `{"level":"W","ts":1698192915.5251079,"logger":"controller","caller":"pkg/controller_utils.go:116","msg":"Pod status updated","after":1.5}`,
+		},
+		"custom": {
+			config: option.EncoderConfig{
+				TimeKey:          "@timestamp",
+				LevelKey:         "severity",
+				NameKey:          "name",
+				CallerKey:        "source",
+				MessageKey:       "message",
+				TimeEncoding:     option.TimeRFC3339Nano,
+				DurationEncoding: option.DurationString,
+				CallerEncoding:   option.CallerFull,
+				LevelEncoding:    option.LevelName,
+			},
+			want: `{"severity":"WARNING","@timestamp":"2023-10-25T00:15:15.525108Z","name":"controller","source":"/src/pkg/controller_utils.go:116","message":"Pod status updated","after":"1.5s"}`,
+		},
+		"epoch millis": {
+			config: option.EncoderConfig{TimeEncoding: option.TimeEpochMillis, DurationEncoding: option.DurationMillis, LevelEncoding: option.LevelLowercase},
+			want:   `{"level":"warning","ts":1698192915525.108,"logger":"controller","caller":"pkg/controller_utils.go:116","msg":"Pod status updated","after":1500}`,
+		},
+		"epoch nanos": {
+			config: option.EncoderConfig{TimeEncoding: option.TimeEpochNanos, DurationEncoding: option.DurationNanos},
+			want:   `{"level":"W","ts":1698192915525108000,"logger":"controller","caller":"pkg/controller_utils.go:116","msg":"Pod status updated","after":1500000000}`,
+		},
+		"layout": {
+			config: option.EncoderConfig{TimeEncoding: "2006-01-02 15:04:05.000", TimeKey: "time"},
+			want:   `{"level":"W","time":"2023-10-25 00:15:15.525","logger":"controller","caller":"pkg/controller_utils.go:116","msg":"Pod status updated","after":1.5}`,
+		},
+		"rfc3339": {
+			config: option.EncoderConfig{TimeEncoding: option.TimeRFC3339, LevelEncoding: option.LevelChar},
+			want:   `{"level":"W","ts":"2023-10-25T00:15:15Z","logger":"controller","caller":"pkg/controller_utils.go:116","msg":"Pod status updated","after":1.5}`,
+		},
+		"unknown time":     {config: option.EncoderConfig{TimeEncoding: "iso"}, wantErr: true},
+		"unknown duration": {config: option.EncoderConfig{DurationEncoding: "hours"}, wantErr: true},
+		"unknown caller":   {config: option.EncoderConfig{CallerEncoding: "long"}, wantErr: true},
+		"unknown level":    {config: option.EncoderConfig{LevelEncoding: "color"}, wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ec, err := NewJSONEncoderConfig(tc.config)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			buf, err := zapcore.NewJSONEncoder(ec).EncodeEntry(ent, fields)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want+"\n", buf.String())
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package zapr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// logfmtEncoder writes entries as logfmt:
+//
+//	ts=2023-10-25T00:15:15.525108Z level=I caller=pkg/controller_utils.go:116 msg="Pod status updated" pod=kubedns
+//
+// Values which contain spaces, quotes, equal signs or control characters
+// are quoted and escaped, slices, maps and structs are JSON-encoded. Keys may not contain any of them, these
+// characters are replaced with underscores.
+type logfmtEncoder struct {
+	*fieldList
+}
+
+// NewLogfmtEncoder returns a zapcore.Encoder for logfmt.
+func NewLogfmtEncoder() zapcore.Encoder {
+	return &logfmtEncoder{fieldList: newFieldList()}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	return &logfmtEncoder{fieldList: enc.clone()}
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	buf.AppendString("ts=")
+	buf.AppendTime(ent.Time, time.RFC3339Nano)
+	buf.AppendString(" level=")
+	buf.AppendByte(levelChar(ent.Level))
+	if ent.LoggerName != "" {
+		writeLogfmtKeyValue(buf, "logger", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		writeLogfmtKeyValue(buf, "caller", ent.Caller.TrimmedPath())
+	}
+	writeLogfmtKeyValue(buf, "msg", ent.Message)
+	for _, kv := range enc.entryFields(fields) {
+		writeLogfmtKeyValue(buf, kv.key, kv.value)
+	}
+	if ent.Stack != "" {
+		writeLogfmtKeyValue(buf, "stacktrace", ent.Stack)
+	}
+	buf.AppendByte('\n')
+	return buf, nil
+}
+
+// writeLogfmtKeyValue appends a space and key=value to buf.
+func writeLogfmtKeyValue(buf *buffer.Buffer, key string, value interface{}) {
+	buf.AppendByte(' ')
+	writeLogfmtKey(buf, key)
+	buf.AppendByte('=')
+	writeLogfmtString(buf, logfmtValue(value))
+}
+
+// writeLogfmtKey appends key to buf with the characters which may not
+// appear in a key replaced by underscores. The empty key becomes "_".
+func writeLogfmtKey(buf *buffer.Buffer, key string) {
+	if key == "" {
+		buf.AppendByte('_')
+		return
+	}
+	for i, r := range key {
+		if logfmtNeedsQuote(r) {
+			buf.AppendByte('_')
+		} else {
+			buf.AppendString(key[i : i+utf8.RuneLen(r)])
+		}
+	}
+}
+
+// logfmtValue returns the unquoted string for value.
+func logfmtValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		s, _ := formatOther(v)
+		return s
+	}
+}
+
+// writeLogfmtString appends s to buf, quoted if it is empty or contains
+// characters which would break up the pair.
+func writeLogfmtString(buf *buffer.Buffer, s string) {
+	quote := s == ""
+	for _, r := range s {
+		if logfmtNeedsQuote(r) {
+			quote = true
+			break
+		}
+	}
+	if quote {
+		buf.AppendString(strconv.Quote(s))
+	} else {
+		buf.AppendString(s)
+	}
+}
+
+// logfmtNeedsQuote reports whether r may not appear in an unquoted key
+// or value.
+func logfmtNeedsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError
+}
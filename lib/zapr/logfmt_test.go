@@ -0,0 +1,52 @@
+package zapr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Date(2023, time.October, 25, 0, 15, 15, 525108000, time.UTC),
+		LoggerName: "controller",
+		Message:    "Pod status updated",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/controller_utils.go", 116, true),
+	}
+	enc := NewLogfmtEncoder()
+	enc.AddString("pod", "kubedns")
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+		zap.Int("count", 3),
+		zap.String("status", `not "ready"`),
+		zap.String("empty", ""),
+		zap.String("a=b c", "x=y"),
+		zap.Duration("after", time.Second),
+		zap.Object("owner", testObject{"dns", 2}),
+		zap.Error(errors.New("timeout")),
+		zap.Any("tags", []string{"a", "b"}),
+		zap.String("lines", "line 1\nline 2"),
+		zap.String("", "no key"),
+		zap.String("größe", "1"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `ts=2023-10-25T00:15:15.525108Z level=E logger=controller caller=pkg/controller_utils.go:116 msg="Pod status updated"`+
+		` pod=kubedns count=3 status="not \"ready\"" empty="" a_b_c="x=y" after=1s owner.name=dns owner.size=2 error=timeout tags="[\"a\",\"b\"]"`+
+		` lines="line 1\nline 2" _="no key" größe=1`+"\n", buf.String())
+
+	buf, err = enc.EncodeEntry(zapcore.Entry{Time: ent.Time, Message: "next", Stack: "goroutine 1\n"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `ts=2023-10-25T00:15:15.525108Z level=I msg=next pod=kubedns stacktrace="goroutine 1\n"`+"\n", buf.String())
+
+	buf = bufferPool.Get()
+	defer buf.Free()
+	assert.Zero(t, testing.AllocsPerRun(10, func() {
+		buf.Reset()
+		writeLogfmtKey(buf, "größe=a b")
+	}))
+	assert.Equal(t, "größe_a_b", buf.String())
+}
@@ -0,0 +1,144 @@
+package zapr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// NameLevel is the verbosity of the loggers with the WithName path Name,
+// like "controller.reconciler", and of all loggers below it.
+type NameLevel struct {
+	Name  string
+	Level int
+}
+
+// NameLevels holds the verbosity of named loggers. The rules may be
+// replaced at any time, also while loggers use them.
+//
+// The zero value has no rules, and so has a nil *NameLevels. It
+// implements option.NameLeveler.
+type NameLevels struct {
+	rules atomic.Pointer[nameRules]
+}
+
+// maxCachedNames bounds the levels cached for one set of rules. Programs
+// which derive names from requests would grow the cache without end;
+// names beyond the bound get matched on every lookup.
+const maxCachedNames = 1024
+
+// nameRules is one set of rules together with the levels looked up so
+// far, which become invalid with the rules.
+type nameRules struct {
+	set    []NameLevel  // As passed to Set.
+	levels []NameLevel  // Sorted by descending name length.
+	cache  sync.Map     // name -> nameLevel
+	cached atomic.Int32 // Number of entries in cache.
+}
+
+type nameLevel struct {
+	level int
+	ok    bool
+}
+
+// Set replaces the rules.
+func (n *NameLevels) Set(levels []NameLevel) {
+	if len(levels) == 0 {
+		n.rules.Store(nil)
+		return
+	}
+	r := &nameRules{
+		set:    append([]NameLevel(nil), levels...),
+		levels: append([]NameLevel(nil), levels...),
+	}
+	// The most specific rule wins, and it is the one with the longest
+	// name among those which match.
+	sort.SliceStable(r.levels, func(i, j int) bool {
+		return len(r.levels[i].Name) > len(r.levels[j].Name)
+	})
+	n.rules.Store(r)
+}
+
+// String returns the rules in the format of ParseNameLevels.
+func (n *NameLevels) String() string {
+	if n == nil {
+		return ""
+	}
+	r := n.rules.Load()
+	if r == nil {
+		return ""
+	}
+	return FormatNameLevels(r.set)
+}
+
+// NameLevel returns the verbosity for loggers with the WithName path
+// name, if there is a rule for it or for one of its parents.
+func (n *NameLevels) NameLevel(name string) (int, bool) {
+	if n == nil || name == "" {
+		return 0, false
+	}
+	r := n.rules.Load()
+	if r == nil {
+		return 0, false
+	}
+	if l, ok := r.cache.Load(name); ok {
+		return l.(nameLevel).level, l.(nameLevel).ok
+	}
+
+	var l nameLevel
+	for _, rule := range r.levels {
+		if name == rule.Name || strings.HasPrefix(name, rule.Name+".") {
+			l = nameLevel{level: rule.Level, ok: true}
+			break
+		}
+	}
+	if r.cached.Load() < maxCachedNames && r.cached.Add(1) <= maxCachedNames {
+		if _, loaded := r.cache.LoadOrStore(name, l); loaded {
+			r.cached.Add(-1)
+		}
+	}
+	return l.level, l.ok
+}
+
+var errNameLevelsSyntax = errors.New("syntax error: expect comma-separated list of name=N")
+
+// ParseNameLevels parses a comma-separated list of name=N, like
+// "controller.reconciler=4,cache=0". The names are WithName paths,
+// joined with dots like zap does.
+func ParseNameLevels(spec string) ([]NameLevel, error) {
+	var levels []NameLevel
+	for _, rule := range strings.Split(spec, ",") {
+		if rule == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(rule, "=")
+		if !ok || name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+			return nil, errNameLevelsSyntax
+		}
+		v, err := strconv.ParseInt(level, 10, 32)
+		if err != nil {
+			return nil, errNameLevelsSyntax
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("negative V level %d for name %q", v, name)
+		}
+		levels = append(levels, NameLevel{Name: name, Level: int(v)})
+	}
+	return levels, nil
+}
+
+// FormatNameLevels is the reverse of ParseNameLevels.
+func FormatNameLevels(levels []NameLevel) string {
+	var b strings.Builder
+	for i, l := range levels {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+		fmt.Fprintf(&b, "%s=%d", l.Name, l.Level)
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package zapr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseNameLevels(t *testing.T) {
+	levels, err := ParseNameLevels("controller.reconciler=4,cache=0,")
+	assert.NoError(t, err)
+	assert.Equal(t, []NameLevel{{"controller.reconciler", 4}, {"cache", 0}}, levels)
+	assert.Equal(t, "controller.reconciler=4,cache=0", FormatNameLevels(levels))
+
+	for _, spec := range []string{"cache", "=1", "cache=x", "cache=-1", ".cache=1", "cache.=1"} {
+		_, err := ParseNameLevels(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNameLevels(t *testing.T) {
+	var n *NameLevels
+	_, ok := n.NameLevel("cache")
+	assert.False(t, ok)
+	assert.Equal(t, "", n.String())
+
+	n = &NameLevels{}
+	n.Set([]NameLevel{{"controller", 1}, {"controller.reconciler", 4}, {"cache", 0}})
+	assert.Equal(t, "controller=1,controller.reconciler=4,cache=0", n.String())
+	for name, want := range map[string]int{
+		"controller":                  1,
+		"controller.queue":            1,
+		"controller.reconciler":       4,
+		"controller.reconciler.child": 4,
+		"cache":                       0,
+		"cache.lru":                   0,
+		"controllers":                 -1,
+		"other":                       -1,
+		"":                            -1,
+	} {
+		// The second round uses the cached levels.
+		for i := 0; i < 2; i++ {
+			level, ok := n.NameLevel(name)
+			if want < 0 {
+				assert.False(t, ok, name)
+			} else if assert.True(t, ok, name) {
+				assert.Equal(t, want, level, name)
+			}
+		}
+	}
+
+	// The cache is bounded.
+	for i := 0; i < 2*maxCachedNames; i++ {
+		level, ok := n.NameLevel(fmt.Sprintf("controller.request-%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, 1, level)
+	}
+	assert.Equal(t, int32(maxCachedNames), n.rules.Load().cached.Load())
+	cached := 0
+	n.rules.Load().cache.Range(func(_, _ any) bool {
+		cached++
+		return true
+	})
+	assert.Equal(t, maxCachedNames, cached)
+
+	n.Set(nil)
+	_, ok = n.NameLevel("controller")
+	assert.False(t, ok)
+}
+
+func TestNameVerbosity(t *testing.T) {
+	core, logs := observer.New(zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	names := &NameLevels{}
+	names.Set([]NameLevel{{"controller", 2}, {"cache", 0}})
+	logger := NewLoggerWithOptions(zap.New(core), NameVerbosity(names))
+
+	controller := logger.WithName("controller")
+	assert.True(t, controller.V(2).Enabled())
+	assert.False(t, controller.V(3).Enabled())
+	controller.V(2).Info("controller v2")
+	controller.V(3).Info("controller v3")
+
+	cache := logger.WithName("cache")
+	assert.True(t, cache.V(0).Enabled())
+	assert.False(t, cache.V(1).Enabled())
+	cache.V(1).Info("cache v1")
+
+	// Loggers without a rule use the level of the core.
+	logger.WithName("other").V(1).Info("other v1")
+	logger.Info("root v0")
+
+	var msgs []string
+	for _, e := range logs.All() {
+		msgs = append(msgs, e.LoggerName+": "+e.Message)
+	}
+	assert.Equal(t, []string{"controller: controller v2", ": root v0"}, msgs)
+
+	// Changing the rules affects existing loggers.
+	names.Set(nil)
+	assert.False(t, controller.V(2).Enabled())
+}
+
+func TestWithVerbosity(t *testing.T) {
+	core, logs := observer.New(zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	names := &NameLevels{}
+	logger := NewLoggerWithOptions(zap.New(core), NameVerbosity(names))
+
+	raised := logger.WithSink(logger.GetSink().(VerbositySink).WithVerbosity(2))
+	assert.True(t, raised.V(2).Enabled())
+	assert.False(t, raised.V(3).Enabled())
+	assert.False(t, logger.V(1).Enabled())
+	raised.V(2).Info("raised v2")
+	raised.V(3).Info("raised v3")
+	logger.V(1).Info("logger v1")
+
+	// Name rules take precedence.
+	names.Set([]NameLevel{{"cache", 0}})
+	raised.WithName("cache").V(1).Info("cache v1")
+
+	var msgs []string
+	for _, e := range logs.All() {
+		msgs = append(msgs, e.Message)
+	}
+	assert.Equal(t, []string{"raised v2"}, msgs)
+}
@@ -0,0 +1,133 @@
+package zapr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tomhjx/xlog/internal/severity"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// pid is written into the header of text entries.
+var pid = os.Getpid()
+
+// textEncoder writes entries in the format of klog:
+//
+//	I1025 00:15:15.525108       1 controller_utils.go:116] "Pod status updated" pod="kubedns" status="ready"
+//
+// The header consists of the severity character, the date and time with
+// microseconds, the process ID and the file and line of the caller.
+type textEncoder struct {
+	*fieldList
+}
+
+// NewTextEncoder returns a zapcore.Encoder for the klog text format.
+func NewTextEncoder() zapcore.Encoder {
+	return &textEncoder{fieldList: newFieldList()}
+}
+
+func (enc *textEncoder) Clone() zapcore.Encoder {
+	return &textEncoder{fieldList: enc.clone()}
+}
+
+func (enc *textEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf := bufferPool.Get()
+
+	// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
+	_, month, day := ent.Time.Date()
+	hour, minute, second := ent.Time.Clock()
+	fmt.Fprintf(buf, "%c%02d%02d %02d:%02d:%02d.%06d %7d ",
+		levelChar(ent.Level), int(month), day, hour, minute, second, ent.Time.Nanosecond()/1000, pid)
+	if ent.Caller.Defined {
+		buf.AppendString(filepath.Base(ent.Caller.File))
+		buf.AppendByte(':')
+		buf.AppendInt(int64(ent.Caller.Line))
+	} else {
+		buf.AppendString("???:1")
+	}
+	buf.AppendString("] ")
+	buf.AppendString(strconv.Quote(ent.Message))
+
+	if ent.LoggerName != "" {
+		writeTextKeyValue(buf, "logger", ent.LoggerName)
+	}
+	for _, kv := range enc.entryFields(fields) {
+		writeTextKeyValue(buf, kv.key, kv.value)
+	}
+	if ent.Stack != "" {
+		writeTextKeyValue(buf, "stacktrace", ent.Stack)
+	}
+	buf.AppendByte('\n')
+	return buf, nil
+}
+
+// levelChar returns the severity character for l.
+func levelChar(l zapcore.Level) byte {
+	return severity.Char[LevelSeverity(l)]
+}
+
+// writeTextKeyValue appends a space and key=value in the klog format to
+// buf. Keys are sanitized like for logfmt. Strings, errors and values
+// with a String method are quoted, other values are formatted by
+// formatOther.
+func writeTextKeyValue(buf *buffer.Buffer, key string, value interface{}) {
+	buf.AppendByte(' ')
+	writeLogfmtKey(buf, key)
+	buf.AppendByte('=')
+
+	switch v := value.(type) {
+	case string:
+		writeTextString(buf, v)
+	case []byte:
+		writeTextString(buf, string(v))
+	case error:
+		writeTextString(buf, v.Error())
+	case fmt.Stringer:
+		writeTextString(buf, v.String())
+	default:
+		if s, ok := formatOther(v); ok {
+			// JSON has no line breaks or spaces outside of strings.
+			buf.AppendString(s)
+		} else {
+			writeTextString(buf, s)
+		}
+	}
+}
+
+// writeTextString appends s quoted to buf. Strings with line breaks are
+// written as they are, indented by a tab, like this:
+//
+//	key=<
+//		line 1
+//		line 2
+//	 >
+//
+// The end delimiter is indented with a space, so it is unambiguous even
+// if a line starts with it.
+func writeTextString(buf *buffer.Buffer, s string) {
+	index := strings.IndexByte(s, '\n')
+	if index == -1 {
+		buf.AppendString(strconv.Quote(s))
+		return
+	}
+
+	buf.AppendString("<\n")
+	for index != -1 {
+		buf.AppendByte('\t')
+		buf.AppendString(s[:index+1])
+		s = s[index+1:]
+		index = strings.IndexByte(s, '\n')
+	}
+	if s == "" {
+		// The string ended with a line break, don't add another.
+		buf.AppendString(" >")
+	} else {
+		buf.AppendByte('\t')
+		buf.AppendString(s)
+		buf.AppendString("\n >")
+	}
+}
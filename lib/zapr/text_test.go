@@ -0,0 +1,61 @@
+package zapr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type testObject struct {
+	Name string
+	Size int
+}
+
+func (o testObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", o.Name)
+	enc.AddInt("size", o.Size)
+	return nil
+}
+
+func TestTextEncoder(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2023, time.October, 25, 0, 15, 15, 525108000, time.Local),
+		LoggerName: "controller",
+		Message:    "Pod status updated",
+		Caller:     zapcore.NewEntryCaller(0, "/src/pkg/controller_utils.go", 116, true),
+	}
+	enc := NewTextEncoder()
+	enc.AddString("pod", "kubedns")
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+		zap.Int("count", 3),
+		zap.String("status", "not \"ready\""),
+		zap.Duration("after", time.Second),
+		zap.Object("owner", testObject{"dns", 2}),
+		zap.Error(errors.New("timeout")),
+		zap.Any("tags", []string{"a", "b"}),
+		zap.Any("spec", struct{ Name string }{"a b"}),
+		zap.Any("nan", struct{ X float64 }{math.NaN()}),
+		zap.String("a=b c", "x"),
+		zap.String("lines", "line 1\nline 2"),
+	})
+	assert.NoError(t, err)
+	want := fmt.Sprintf(`W1025 00:15:15.525108 %7d controller_utils.go:116] "Pod status updated" logger="controller"`, pid) +
+		` pod="kubedns" count=3 status="not \"ready\"" after="1s" owner.name="dns" owner.size=2 error="timeout" tags=["a","b"]` +
+		` spec={"Name":"a b"} nan="{X:NaN}" a_b_c="x"` +
+		" lines=<\n\tline 1\n\tline 2\n >\n"
+	assert.Equal(t, want, buf.String())
+
+	// Fields of an entry don't end up in the encoder.
+	buf, err = enc.EncodeEntry(zapcore.Entry{Time: ent.Time, Message: "next", Stack: "goroutine 1\n"}, nil)
+	assert.NoError(t, err)
+	want = fmt.Sprintf(`I1025 00:15:15.525108 %7d ???:1] "next" pod="kubedns"`, pid) +
+		" stacktrace=<\n\tgoroutine 1\n >\n"
+	assert.Equal(t, want, buf.String())
+}
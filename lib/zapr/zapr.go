@@ -55,8 +55,11 @@ package zapr
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/go-logr/logr"
+	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/option"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -90,6 +93,18 @@ type zapLogger struct {
 	// that explain why a call was invalid (for example,
 	// non-string key). This is enabled by default.
 	panicMessages bool
+
+	// closeOutputs releases the outputs opened for l by Build. It is
+	// shared by all loggers derived from the same root.
+	closeOutputs func()
+
+	// names overrides the verbosity of l for some logger names.
+	names option.NameLeveler
+
+	// verbosity, if set, is the V level up to which zl logs
+	// regardless of the level of the core, see WithVerbosity.
+	verbosity    int
+	hasVerbosity bool
 }
 
 const (
@@ -201,12 +216,49 @@ func toZapLevel(lvl int) zapcore.Level {
 	return 0 - zapcore.Level(lvl)
 }
 
+// NameLevel implements NameVerbositySink.
+func (zl *zapLogger) NameLevel() (int, bool) {
+	if zl.names == nil {
+		return 0, false
+	}
+	return zl.names.NameLevel(zl.l.Name())
+}
+
+// vLevel returns the V level up to which zl logs regardless of the
+// level of the core. Name rules take precedence over WithVerbosity.
+func (zl *zapLogger) vLevel() (int, bool) {
+	if v, ok := zl.NameLevel(); ok {
+		return v, true
+	}
+	return zl.verbosity, zl.hasVerbosity
+}
+
+// WithVerbosity implements VerbositySink.
+func (zl *zapLogger) WithVerbosity(level int) logr.LogSink {
+	newLogger := *zl
+	newLogger.verbosity = level
+	newLogger.hasVerbosity = true
+	return &newLogger
+}
+
 func (zl zapLogger) Enabled(lvl int) bool {
+	if v, ok := zl.vLevel(); ok {
+		return lvl <= v && zl.l.Core().Enabled(zapcore.InfoLevel)
+	}
 	return zl.l.Core().Enabled(toZapLevel(lvl))
 }
 
 func (zl *zapLogger) Info(lvl int, msg string, keysAndVals ...interface{}) {
-	if checkedEntry := zl.l.Check(toZapLevel(lvl), msg); checkedEntry != nil {
+	zapLevel := toZapLevel(lvl)
+	if v, ok := zl.vLevel(); ok {
+		if lvl > v {
+			return
+		}
+		// The rule replaces the verbosity of the core, which would
+		// drop the entry otherwise.
+		zapLevel = zapcore.InfoLevel
+	}
+	if checkedEntry := zl.l.Check(zapLevel, msg); checkedEntry != nil {
 		checkedEntry.Write(zl.handleFields(lvl, keysAndVals)...)
 	}
 }
@@ -217,6 +269,22 @@ func (zl *zapLogger) Error(err error, msg string, keysAndVals ...interface{}) {
 	}
 }
 
+// LogSeverity implements SeverityLogSink.
+func (zl *zapLogger) LogSeverity(s severity.Severity, err error, msg string, keysAndVals ...interface{}) {
+	if checkedEntry := zl.l.Check(SeverityLevel(s), msg); checkedEntry != nil {
+		if s < severity.ErrorLog {
+			// Only info entries have a V level.
+			lvl := noLevel
+			if s == severity.InfoLog {
+				lvl = 0
+			}
+			checkedEntry.Write(zl.handleFields(lvl, keysAndVals)...)
+			return
+		}
+		checkedEntry.Write(zl.handleFields(noLevel, keysAndVals, zap.NamedError(zl.errorKey, err))...)
+	}
+}
+
 func (zl *zapLogger) WithValues(keysAndValues ...interface{}) logr.LogSink {
 	newLogger := *zl
 	newLogger.l = zl.l.With(zl.handleFields(noLevel, keysAndValues)...)
@@ -235,6 +303,15 @@ func (zl *zapLogger) WithCallDepth(depth int) logr.LogSink {
 	return &newLogger
 }
 
+// WithoutStacktrace implements StacktraceSink.
+func (zl *zapLogger) WithoutStacktrace() SeverityLogSink {
+	newLogger := *zl
+	newLogger.l = zl.l.WithOptions(zap.AddStacktrace(zap.LevelEnablerFunc(func(zapcore.Level) bool {
+		return false
+	})))
+	return &newLogger
+}
+
 // Underlier exposes access to the underlying logging implementation.  Since
 // callers only have a logr.Logger, they have to know which implementation is
 // in use, so this interface is less of an abstraction and more of way to test
@@ -247,6 +324,56 @@ func (zl *zapLogger) GetUnderlying() *zap.Logger {
 	return zl.l
 }
 
+// SeverityLogSink is a LogSink which can log entries with any severity,
+// not just the info and error entries which logr supports. The severity
+// maps to the zap level, so warnings are logged at WarnLevel and fatal
+// messages at FatalLevel. Logging a fatal message does not terminate the
+// program; that is left to the caller.
+//
+// Like the other LogSink methods, LogSeverity expects to be called
+// through one additional stack frame.
+type SeverityLogSink interface {
+	logr.LogSink
+	LogSeverity(s severity.Severity, err error, msg string, keysAndVals ...interface{})
+}
+
+// StacktraceSink is a SeverityLogSink which adds the stack trace of the
+// caller to error and fatal entries, unless it is told not to.
+type StacktraceSink interface {
+	SeverityLogSink
+	// WithoutStacktrace returns a sink which adds no stack traces.
+	WithoutStacktrace() SeverityLogSink
+}
+
+// NameVerbositySink is a LogSink with verbosity rules for some logger
+// names, see NameVerbosity. Such rules replace other verbosity settings
+// which callers may have.
+type NameVerbositySink interface {
+	logr.LogSink
+	// NameLevel returns the verbosity which the rules set for the
+	// name of the logger, if there is a rule for it.
+	NameLevel() (level int, ok bool)
+}
+
+// VerbositySink is a LogSink whose verbosity can be raised above the
+// level of its core, like V does for the call sites which -vmodule
+// enables. Name rules still take precedence.
+type VerbositySink interface {
+	logr.LogSink
+	// WithVerbosity returns a sink which logs V levels up to level.
+	WithVerbosity(level int) logr.LogSink
+}
+
+// Close releases the outputs which were opened by Build for this logger
+// and for all loggers derived from it. It does nothing for loggers
+// created from an existing zap.Logger.
+func (zl *zapLogger) Close() error {
+	if zl.closeOutputs != nil {
+		zl.closeOutputs()
+	}
+	return nil
+}
+
 // NewLogger creates a new logr.Logger using the given Zap Logger to log.
 func NewLogger(l *zap.Logger) logr.Logger {
 	return NewLoggerWithOptions(l)
@@ -312,5 +439,25 @@ func DPanicOnBugs(enabled bool) Option {
 	}
 }
 
+// NameVerbosity overrides the verbosity for loggers whose WithName
+// path has a rule in names. It takes precedence over the level of the
+// zap core for V levels, so the core may drop entries which the rule
+// allows and vice versa.
+func NameVerbosity(names option.NameLeveler) Option {
+	return func(zl *zapLogger) {
+		zl.names = names
+	}
+}
+
+// closeWith sets the function which releases the outputs of the logger.
+func closeWith(closeOutputs func()) Option {
+	return func(zl *zapLogger) {
+		zl.closeOutputs = closeOutputs
+	}
+}
+
 var _ logr.LogSink = &zapLogger{}
 var _ logr.CallDepthLogSink = &zapLogger{}
+var _ io.Closer = &zapLogger{}
+var _ SeverityLogSink = &zapLogger{}
+var _ NameVerbositySink = &zapLogger{}
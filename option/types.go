@@ -1,8 +1,174 @@
 package option
 
+import (
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Encodings of the log entries.
+const (
+	// EncodingJSON writes one JSON object per entry. It is the default.
+	EncodingJSON = "json"
+	// EncodingText writes the text format of klog, a header with the
+	// severity, time, process ID and caller followed by the quoted
+	// message and key="value" pairs.
+	EncodingText = "text"
+	// EncodingLogfmt writes one line of logfmt key=value pairs per entry.
+	EncodingLogfmt = "logfmt"
+	// EncodingConsole writes aligned columns for reading the log in a
+	// terminal during development. It is used for stderr when Encoding
+	// is empty and stderr is a terminal.
+	EncodingConsole = "console"
+)
+
+// Color modes of EncodingConsole. Colors are only ever written to stderr,
+// never to the log file.
+const (
+	// ColorAuto colors the output if stderr is a terminal and the
+	// NO_COLOR environment variable is unset or empty. It is the
+	// default.
+	ColorAuto = "auto"
+	// ColorAlways colors the output even if stderr is no terminal.
+	ColorAlways = "always"
+	// ColorNever disables colors.
+	ColorNever = "never"
+)
+
+// Time encodings of EncoderConfig. Any other value is a layout for
+// time.Time.Format.
+const (
+	// TimeEpoch writes the seconds since the Unix epoch as a floating
+	// point number. It is the default.
+	TimeEpoch = "epoch"
+	// TimeEpochMillis writes the milliseconds since the Unix epoch as a
+	// floating point number.
+	TimeEpochMillis = "epochmillis"
+	// TimeEpochNanos writes the nanoseconds since the Unix epoch as an
+	// integer.
+	TimeEpochNanos = "epochnanos"
+	// TimeRFC3339 writes a string like 2006-01-02T15:04:05Z07:00.
+	TimeRFC3339 = "rfc3339"
+	// TimeRFC3339Nano writes a string like
+	// 2006-01-02T15:04:05.999999999Z07:00.
+	TimeRFC3339Nano = "rfc3339nano"
+)
+
+// Duration encodings of EncoderConfig.
+const (
+	// DurationSeconds writes seconds as a floating point number. It is
+	// the default.
+	DurationSeconds = "seconds"
+	// DurationMillis writes milliseconds as a floating point number.
+	DurationMillis = "millis"
+	// DurationNanos writes nanoseconds as an integer.
+	DurationNanos = "nanos"
+	// DurationString writes a string like 1m30s.
+	DurationString = "string"
+)
+
+// Caller encodings of EncoderConfig.
+const (
+	// CallerShort writes the package path and file name, like
+	// pkg/file.go:42. It is the default.
+	CallerShort = "short"
+	// CallerFull writes the full path of the file.
+	CallerFull = "full"
+)
+
+// Level encodings of EncoderConfig.
+const (
+	// LevelChar writes the severity character, I, W, E or F. It is the
+	// default.
+	LevelChar = "char"
+	// LevelName writes the severity name, like INFO or WARNING.
+	LevelName = "name"
+	// LevelLowercase writes the severity name in lower case, like info
+	// or warning.
+	LevelLowercase = "lowercase"
+)
+
+// EncoderConfig customizes EncodingJSON. Empty fields keep the defaults,
+// which are the settings of zap.NewProductionEncoderConfig except for the
+// level, which is written as severity character.
+type EncoderConfig struct {
+	// The keys of the parts of an entry, "ts", "level", "logger",
+	// "caller", "msg" and "stacktrace" by default.
+	TimeKey       string `json:"timeKey" yaml:"timeKey"`
+	LevelKey      string `json:"levelKey" yaml:"levelKey"`
+	NameKey       string `json:"nameKey" yaml:"nameKey"`
+	CallerKey     string `json:"callerKey" yaml:"callerKey"`
+	MessageKey    string `json:"messageKey" yaml:"messageKey"`
+	StacktraceKey string `json:"stacktraceKey" yaml:"stacktraceKey"`
+
+	// TimeEncoding is one of the Time constants or a layout for
+	// time.Time.Format, like "2006-01-02 15:04:05.000".
+	TimeEncoding string `json:"timeEncoding" yaml:"timeEncoding"`
+	// DurationEncoding is one of the Duration constants.
+	DurationEncoding string `json:"durationEncoding" yaml:"durationEncoding"`
+	// CallerEncoding is one of the Caller constants.
+	CallerEncoding string `json:"callerEncoding" yaml:"callerEncoding"`
+	// LevelEncoding is one of the Level constants.
+	LevelEncoding string `json:"levelEncoding" yaml:"levelEncoding"`
+}
+
+// NameLeveler provides the verbosity of named loggers.
+type NameLeveler interface {
+	// NameLevel returns the verbosity for loggers with the WithName
+	// path name, joined with dots, and whether there is a rule for
+	// that name at all.
+	NameLevel(name string) (level int, ok bool)
+}
+
+// BacktraceMatcher selects the log call sites whose entries get the stack
+// of the logging goroutine attached.
+type BacktraceMatcher interface {
+	// MatchBacktrace reports whether file and line, the caller of an
+	// entry, are such a call site.
+	MatchBacktrace(file string, line int) bool
+}
+
+// LogOption describes the outputs of a logger. Stderr is always written
+// to; OutputPath adds a file which gets rotated according to the
+// remaining file options. Both outputs receive the entries enabled by
+// Level, StderrLevel restricts those which are written to stderr.
 type LogOption struct {
 	OutputPath string
 	MaxSizeMB  int
 	MaxAgeDay  int
 	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+	// UTC uses UTC instead of the local time for the timestamps in the
+	// names of rotated files.
+	UTC bool
+	// FileMode is the permission of the log files, 0600 if zero.
+	FileMode os.FileMode
+	// DirMode is the permission of missing parent directories of
+	// OutputPath, which get created when the logger is built. 0755 if
+	// zero.
+	DirMode os.FileMode
+	// Encoding selects how entries are written. If empty, it is
+	// EncodingConsole for stderr if that is a terminal and EncodingJSON
+	// otherwise.
+	Encoding string
+	// Color is the color mode of EncodingConsole, ColorAuto if empty.
+	Color string
+	// EncoderConfig customizes EncodingJSON.
+	EncoderConfig EncoderConfig
+	// Level is the threshold below which entries are dropped, InfoLevel
+	// if nil. Passing a zap.AtomicLevel allows changing it later on.
+	// V levels map to zap levels below InfoLevel, V(1) is DebugLevel,
+	// V(2) is -2 and so on.
+	Level zapcore.LevelEnabler
+	// NameLevels overrides the verbosity of named loggers. Info entries
+	// of such loggers are only subject to Level at InfoLevel, whatever
+	// their V level.
+	NameLevels NameLeveler
+	// StderrLevel is the threshold for entries written to stderr, in
+	// addition to Level. Nil means that stderr receives all entries.
+	StderrLevel zapcore.LevelEnabler
+	// BacktraceAt attaches the stack of the logging goroutine to the
+	// entries logged at the call sites it matches.
+	BacktraceAt BacktraceMatcher
 }
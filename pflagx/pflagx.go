@@ -0,0 +1,27 @@
+// Package pflagx registers the xlog command line flags on a
+// github.com/spf13/pflag FlagSet, as used by cobra based commands.
+//
+// The flags are the ones defined by xlog.InitFlags, so a program is
+// configured identically whether it parses its command line with the
+// standard flag package or with pflag.
+package pflagx
+
+import (
+	"flag"
+
+	"github.com/spf13/pflag"
+	"github.com/tomhjx/xlog"
+)
+
+// InitFlags registers the xlog flags on flagset, or on pflag.CommandLine
+// if flagset is nil. Flags which are already defined in flagset are
+// left alone.
+func InitFlags(flagset *pflag.FlagSet) {
+	if flagset == nil {
+		flagset = pflag.CommandLine
+	}
+
+	fs := flag.NewFlagSet("xlog", flag.ContinueOnError)
+	xlog.InitFlags(fs)
+	flagset.AddGoFlagSet(fs)
+}
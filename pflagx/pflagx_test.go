@@ -0,0 +1,42 @@
+package pflagx
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	InitFlags(fs)
+
+	types := map[string]string{
+		"v":                    "Level",
+		"severity":             "severity",
+		"stderrthreshold":      "severity",
+		"log_file":             "string",
+		"log_file_max_size":    "int",
+		"log_file_max_age":     "int",
+		"log_file_max_backups": "int",
+		"log_file_compress":    "bool",
+		"log_file_utc":         "bool",
+		"log_file_mode":        "fileMode",
+		"contextual":           "bool",
+	}
+	for name, typ := range types {
+		f := fs.Lookup(name)
+		if assert.NotNil(t, f, name) {
+			assert.Equal(t, typ, f.Value.Type(), name)
+			assert.NotEmpty(t, f.Usage, name)
+		}
+	}
+
+	assert.NoError(t, fs.Parse([]string{"--v=2", "--severity=ERROR", "--contextual"}))
+	assert.Equal(t, "2", fs.Lookup("v").Value.String())
+	assert.Equal(t, "2", fs.Lookup("severity").Value.String())
+	assert.Error(t, fs.Parse([]string{"--log_file_max_size=big"}))
+
+	// Registering twice must not panic on the existing flags.
+	InitFlags(fs)
+}
@@ -1,37 +1,108 @@
 package xlog
 
 import (
+	"os"
+
 	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/lib/zapr"
 )
 
 func SetVerbosity(v int) {
-	logging.setVState(Level(v))
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.stopRevert(&logging.verbosityRevert)
+	logging.setVState(Level(v), logging.vmodule.filter, false)
+}
+
+// SetVModule sets the per-file verbosity, like "gopher*=3,github.com/example/*=1".
+func SetVModule(spec string) error {
+	return logging.vmodule.Set(spec)
 }
 
 func SetSeverity(s severity.Severity) {
-	logging.severity = severityValue{
-		Severity: s,
-	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.stopRevert(&logging.severityRevert)
+	logging.severity.set(s)
+}
+
+// SetStderrThreshold sets the threshold for stderr while a file is written
+// to, ERROR by default. It does not change the severity threshold.
+func SetStderrThreshold(s severity.Severity) {
+	logging.stderrThreshold.set(s)
+}
+
+func SetLogToStderr(b bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.toStderr = b
+}
+
+func SetAlsoLogToStderr(b bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.alsoToStderr = b
 }
 
 func SetFile(p string) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
 	logging.file = p
 }
 
 func SetFileMaxSizeMB(p int) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
 	logging.fileMaxSizeMB = p
 }
 
 func SetFileMaxAgeDay(p int) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
 	logging.fileMaxAgeDay = p
 }
 
 func SetFileMaxBackups(p int) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
 	logging.fileMaxBackups = p
 }
 
+func SetFileCompress(b bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fileCompress = b
+}
+
+func SetFileUTC(b bool) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fileUTC = b
+}
+
+func SetFileMode(m os.FileMode) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.fileMode = m
+}
+
+// SetNameVerbosity sets the verbosity of named loggers, like "controller.reconciler=4,cache=0".
+func SetNameVerbosity(spec string) error {
+	levels, err := zapr.ParseNameLevels(spec)
+	if err != nil {
+		return err
+	}
+	logging.vname.Set(levels)
+	return nil
+}
+
+// SetBacktraceAt makes entries logged at location, like "gopher.go:42", carry a stack trace.
+func SetBacktraceAt(location string) error {
+	return logging.backtraceAt.Set(location)
+}
+
 func SwitchContextual(b bool) {
-	logging.settings.contextualLoggingEnabled = b
+	logging.contextualLoggingEnabled.Store(b)
 }
 
 func SetSeverityName(s string) {
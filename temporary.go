@@ -0,0 +1,81 @@
+package xlog
+
+import (
+	"time"
+
+	"github.com/tomhjx/xlog/internal/severity"
+)
+
+// revert undoes a temporary change of a setting once its time is up.
+type revert struct {
+	timer *time.Timer
+	// undo restores the value from before the change. It is called
+	// with l.mu held.
+	undo func()
+}
+
+// temporarily schedules undo to run after d, unless the change gets
+// cancelled with the returned function or made permanent with
+// stopRevert first. A pending revert of the same setting is replaced,
+// but its undo is kept, so that the value from before the first of
+// several overlapping changes gets restored. l.mu is held.
+func (l *loggingT) temporarily(pending **revert, undo func(), d time.Duration) (cancel func()) {
+	if *pending != nil {
+		(*pending).timer.Stop()
+		undo = (*pending).undo
+	}
+	r := &revert{undo: undo}
+	*pending = r
+
+	run := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if *pending != r {
+			// Replaced, cancelled or made permanent.
+			return
+		}
+		*pending = nil
+		r.undo()
+	}
+	r.timer = time.AfterFunc(d, run)
+	return func() {
+		r.timer.Stop()
+		run()
+	}
+}
+
+// stopRevert makes a temporary change permanent. l.mu is held.
+func (l *loggingT) stopRevert(pending **revert) {
+	if *pending != nil {
+		(*pending).timer.Stop()
+		*pending = nil
+	}
+}
+
+// SetVerbosityFor sets the verbosity to level for the duration d, or
+// until cancel gets called, and then restores the previous verbosity.
+// Overlapping changes restore the verbosity from before the first one.
+func SetVerbosityFor(level Level, d time.Duration) (cancel func()) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	prev := logging.verbosity.get()
+	cancel = logging.temporarily(&logging.verbosityRevert, func() {
+		logging.setVState(prev, logging.vmodule.filter, false)
+	}, d)
+	logging.setVState(level, logging.vmodule.filter, false)
+	return cancel
+}
+
+// SetSeverityFor is like SetVerbosityFor for the severity threshold.
+func SetSeverityFor(s severity.Severity, d time.Duration) (cancel func()) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+
+	prev := logging.severity.get()
+	cancel = logging.temporarily(&logging.severityRevert, func() {
+		logging.severity.set(prev)
+	}, d)
+	logging.severity.set(s)
+	return cancel
+}
@@ -0,0 +1,67 @@
+package xlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomhjx/xlog/internal/severity"
+)
+
+func TestSetVerbosityFor(t *testing.T) {
+	SetVerbosity(1)
+	defer SetVerbosity(0)
+
+	SetVerbosityFor(3, 10*time.Millisecond)
+	assert.True(t, V(3).Enabled())
+	assert.Eventually(t, func() bool {
+		return logging.verbosity.get() == 1
+	}, time.Second, time.Millisecond)
+
+	cancel := SetVerbosityFor(3, time.Hour)
+	assert.Equal(t, Level(3), logging.verbosity.get())
+	cancel()
+	assert.Equal(t, Level(1), logging.verbosity.get())
+	cancel()
+	assert.Equal(t, Level(1), logging.verbosity.get())
+}
+
+func TestSetVerbosityForOverlapping(t *testing.T) {
+	SetVerbosity(0)
+	defer SetVerbosity(0)
+
+	// The second change replaces the first one, but restores the
+	// verbosity from before both.
+	first := SetVerbosityFor(2, time.Hour)
+	SetVerbosityFor(4, 10*time.Millisecond)
+	assert.Equal(t, Level(4), logging.verbosity.get())
+	assert.Eventually(t, func() bool {
+		return logging.verbosity.get() == 0
+	}, time.Second, time.Millisecond)
+	first()
+	assert.Equal(t, Level(0), logging.verbosity.get())
+
+	// Setting the verbosity makes the change permanent.
+	cancel := SetVerbosityFor(2, 10*time.Millisecond)
+	SetVerbosity(3)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, Level(3), logging.verbosity.get())
+	cancel()
+	assert.Equal(t, Level(3), logging.verbosity.get())
+}
+
+func TestSetSeverityFor(t *testing.T) {
+	SetSeverity(severity.WarningLog)
+	defer SetSeverity(severity.InfoLog)
+
+	SetSeverityFor(severity.InfoLog, 10*time.Millisecond)
+	assert.Equal(t, severity.InfoLog, logging.severity.get())
+	assert.Eventually(t, func() bool {
+		return logging.severity.get() == severity.WarningLog
+	}, time.Second, time.Millisecond)
+
+	cancel := SetSeverityFor(severity.ErrorLog, time.Hour)
+	assert.Equal(t, severity.ErrorLog, logging.severity.get())
+	cancel()
+	assert.Equal(t, severity.WarningLog, logging.severity.get())
+}
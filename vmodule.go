@@ -0,0 +1,182 @@
+package xlog
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// modulePat contains a filter for the -vmodule flag.
+// It holds a verbosity level and a file pattern to match.
+type modulePat struct {
+	pattern string
+	literal bool // The pattern is a literal string
+	level   Level
+}
+
+// match reports whether the call site matches the pattern. Patterns
+// without a slash match the base name of the file without the ".go"
+// suffix, others the import path of the package, optionally followed
+// by such a base name.
+func (m *modulePat) match(pkg, file string) bool {
+	if !strings.Contains(m.pattern, "/") {
+		return m.matchString(file)
+	}
+	return m.matchString(pkg) || m.matchString(pkg+"/"+file)
+}
+
+func (m *modulePat) matchString(s string) bool {
+	if m.literal {
+		return s == m.pattern
+	}
+	match, _ := path.Match(m.pattern, s)
+	return match
+}
+
+// moduleSpec represents the setting of the -vmodule flag.
+type moduleSpec struct {
+	filter []modulePat
+}
+
+func (m *moduleSpec) String() string {
+	// Lock because the type is not atomic.
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	return m.string()
+}
+
+// string is String without locking. l.mu is held.
+func (m *moduleSpec) string() string {
+	var b strings.Builder
+	for i, f := range m.filter {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+		fmt.Fprintf(&b, "%s=%d", f.pattern, f.level)
+	}
+	return b.String()
+}
+
+// Get is part of the (Go 1.2) flag.Getter interface. It always returns nil for this flag type since the
+// struct is not exported.
+func (m *moduleSpec) Get() interface{} {
+	return nil
+}
+
+// Type is part of the pflag.Value interface.
+func (m *moduleSpec) Type() string {
+	return "pattern=N,..."
+}
+
+var errVmoduleSyntax = errors.New("syntax error: expect comma-separated list of pattern=N")
+
+// Set is part of the flag.Value interface. The syntax of the argument is
+// a comma-separated list of pattern=N, where pattern is a literal file
+// name (minus the ".go" suffix), a package import path, or a "glob"
+// pattern of either, and N is a V level. For instance:
+//
+//	-vmodule=gopher*=3,github.com/example/cache=2
+//
+// sets the V level to 3 in all Go files whose names begin "gopher" and
+// to 2 in all files of the cache package. The first matching pattern
+// wins.
+func (m *moduleSpec) Set(value string) error {
+	filter, err := parseModuleSpec(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setVState(logging.verbosity.get(), filter, true)
+	return nil
+}
+
+// parseModuleSpec parses the argument of the -vmodule flag.
+func parseModuleSpec(value string) ([]modulePat, error) {
+	var filter []modulePat
+	for _, pat := range strings.Split(value, ",") {
+		if len(pat) == 0 {
+			// Empty strings such as from a trailing comma can be ignored.
+			continue
+		}
+		patLev := strings.Split(pat, "=")
+		if len(patLev) != 2 || len(patLev[0]) == 0 || len(patLev[1]) == 0 {
+			return nil, errVmoduleSyntax
+		}
+		pattern := patLev[0]
+		v, err := strconv.ParseInt(patLev[1], 10, 32)
+		if err != nil {
+			return nil, errVmoduleSyntax
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("negative V level %d for pattern %q", v, pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		filter = append(filter, modulePat{pattern, isLiteral(pattern), Level(v)})
+	}
+	return filter, nil
+}
+
+// isLiteral reports whether the pattern is a literal string, that is, has no metacharacters
+// that require filepath.Match to be called to match the pattern.
+func isLiteral(pattern string) bool {
+	return !strings.ContainsAny(pattern, `\*?[]`)
+}
+
+// vmoduleEnabled reports whether the vmodule filter enables level for
+// the call site depth+1 frames up the stack. The result for a call site
+// is cached, so the stack gets walked just once per call site and
+// filter.
+func (l *loggingT) vmoduleEnabled(level Level, depth int) bool {
+	if atomic.LoadInt32(&l.filterLength) == 0 {
+		return false
+	}
+
+	var pcs [1]uintptr
+	if runtime.Callers(depth+2, pcs[:]) == 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, ok := l.vmap[pcs[0]]
+	if !ok {
+		v = l.setV(pcs[0])
+	}
+	return v >= level
+}
+
+// setV computes and remembers the V level for a given PC
+// when vmodule is enabled.
+// File pattern matching takes the basename of the file, stripped
+// of its .go suffix, and uses path.Match, which is a little more
+// general than the *? matching used in C++.
+// l.mu is held.
+func (l *loggingT) setV(pc uintptr) Level {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	file := strings.TrimSuffix(path.Base(frame.File), ".go")
+	pkg := funcPackage(frame.Function)
+	for _, filter := range l.vmodule.filter {
+		if filter.match(pkg, file) {
+			l.vmap[pc] = filter.level
+			return filter.level
+		}
+	}
+	l.vmap[pc] = 0
+	return 0
+}
+
+// funcPackage returns the import path of the package of a function with
+// the fully qualified name fn, as reported by runtime.Frame.Function.
+func funcPackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
@@ -0,0 +1,93 @@
+package xlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseModuleSpec(t *testing.T) {
+	tests := map[string]struct {
+		spec    string
+		want    []modulePat
+		wantErr bool
+	}{
+		"empty":    {spec: ""},
+		"literal":  {spec: "gopher=3", want: []modulePat{{"gopher", true, 3}}},
+		"glob":     {spec: "gopher*=3,", want: []modulePat{{"gopher*", false, 3}}},
+		"package":  {spec: "github.com/example/cache=0", want: []modulePat{{"github.com/example/cache", true, 0}}},
+		"several":  {spec: "a=1,b/*=2", want: []modulePat{{"a", true, 1}, {"b/*", false, 2}}},
+		"no level": {spec: "gopher", wantErr: true},
+		"no name":  {spec: "=1", wantErr: true},
+		"negative": {spec: "gopher=-1", wantErr: true},
+		"bad glob": {spec: "gopher[=1", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseModuleSpec(tc.spec)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestVModule(t *testing.T) {
+	SetVerbosity(0)
+	t.Cleanup(func() {
+		assert.NoError(t, SetVModule(""))
+	})
+
+	tests := map[string]struct {
+		spec string
+		want Level
+	}{
+		"file":            {spec: "vmodule_test=3", want: 3},
+		"file glob":       {spec: "other=5,vmodule_*=2", want: 2},
+		"package":         {spec: "github.com/tomhjx/xlog=4", want: 4},
+		"package glob":    {spec: "github.com/tomhjx/*=1", want: 1},
+		"package file":    {spec: "github.com/tomhjx/xlog/vmodule_test=2", want: 2},
+		"first match":     {spec: "vmodule_test=0,github.com/tomhjx/xlog=4", want: 0},
+		"other file":      {spec: "xlog_test=3", want: 0},
+		"other package":   {spec: "github.com/tomhjx/xlog/lib/zapr=3", want: 0},
+		"file with slash": {spec: "xlog/vmodule_test=3", want: 0},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, SetVModule(tc.spec))
+			assert.Equal(t, tc.spec, logging.vmodule.String())
+			for i := 0; i < 2; i++ {
+				// The second round uses the cached levels.
+				assert.True(t, V(tc.want).enabled, "V(%d)", tc.want)
+				assert.False(t, V(tc.want+1).enabled, "V(%d)", tc.want+1)
+			}
+		})
+	}
+}
+
+func TestVModuleVerbosity(t *testing.T) {
+	assert.NoError(t, SetVModule("vmodule_test=2"))
+	SetVerbosity(4)
+	t.Cleanup(func() {
+		SetVerbosity(0)
+		assert.NoError(t, SetVModule(""))
+	})
+
+	// The global verbosity still applies when it is higher.
+	assert.True(t, V(4).enabled)
+	assert.Equal(t, "vmodule_test=2", CurrentConfig().VModule)
+}
+
+func TestVDepth(t *testing.T) {
+	SetVerbosity(0)
+	assert.NoError(t, SetVModule("vmodule_test=2"))
+	defer func() { assert.NoError(t, SetVModule("")) }()
+
+	assert.True(t, VDepth(0, 2).Enabled())
+	assert.False(t, VDepth(0, 3).Enabled())
+	assert.True(t, vDepthHelper(1, 2).Enabled())
+	assert.False(t, vDepthHelper(0, 2).Enabled())
+}
@@ -0,0 +1,115 @@
+package xlog
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConfigWatcher keeps the global logger in sync with a configuration
+// file. See WatchConfigFile.
+type ConfigWatcher struct {
+	path     string
+	interval time.Duration
+	// base is the configuration the file gets applied to on each
+	// reload, so that removing a key from the file restores the value
+	// it had before watching started.
+	base Config
+
+	signals  chan os.Signal
+	stopC    chan struct{}
+	stopDone chan struct{}
+	stopOnce sync.Once
+
+	// modTime and size identify the version of the file which was
+	// loaded last.
+	modTime time.Time
+	size    int64
+}
+
+// WatchConfigFile applies the configuration file at path like
+// LoadConfigFile and reloads it when it changes, checked every interval,
+// or on SIGHUP. Errors while reloading are logged and leave the
+// configuration unchanged. Replace the file atomically, by renaming a
+// new file over it, so that it is never read half written.
+func WatchConfigFile(path string, interval time.Duration) (*ConfigWatcher, error) {
+	w := &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		base:     CurrentConfig(),
+		signals:  make(chan os.Signal, 1),
+		stopC:    make(chan struct{}),
+		stopDone: make(chan struct{}),
+	}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	signal.Notify(w.signals, syscall.SIGHUP)
+	go w.run()
+	return w, nil
+}
+
+// Stop ends watching and waits until a reload in progress has completed.
+// The configuration stays as it is.
+func (w *ConfigWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		signal.Stop(w.signals)
+		close(w.stopC)
+	})
+	<-w.stopDone
+}
+
+func (w *ConfigWatcher) run() {
+	defer close(w.stopDone)
+
+	var ticker <-chan time.Time
+	if w.interval > 0 {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+		ticker = t.C
+	}
+	for {
+		select {
+		case <-ticker:
+			fi, err := os.Stat(w.path)
+			if err != nil {
+				// Report a vanished file once, not on every poll.
+				if !w.modTime.IsZero() {
+					ErrorS(err, "Failed to check logging configuration", "path", w.path)
+					w.modTime, w.size = time.Time{}, 0
+				}
+				continue
+			}
+			if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+				continue
+			}
+		case <-w.signals:
+		case <-w.stopC:
+			return
+		}
+		if err := w.reload(); err != nil {
+			ErrorS(err, "Failed to reload logging configuration", "path", w.path)
+			continue
+		}
+		InfoS("Reloaded logging configuration", "path", w.path)
+	}
+}
+
+// reload applies the current content of the file.
+func (w *ConfigWatcher) reload() error {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	// Remember the version even if it turns out to be invalid, it
+	// does not get better by reading it again.
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+
+	c, err := readConfigFile(w.path, w.base)
+	if err != nil {
+		return err
+	}
+	return Configure(c)
+}
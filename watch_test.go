@@ -0,0 +1,82 @@
+package xlog
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	prev := saveConfig(t)
+	path := filepath.Join(t.TempDir(), "xlog.yaml")
+	mtime := time.Now()
+	write := func(content string) {
+		// Replace the file atomically, the watcher must not see it
+		// half written.
+		tmp := path + ".tmp"
+		assert.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+		// Make sure the change is visible even with coarse timestamps.
+		mtime = mtime.Add(time.Second)
+		assert.NoError(t, os.Chtimes(tmp, mtime, mtime))
+		assert.NoError(t, os.Rename(tmp, path))
+	}
+
+	write("verbosity: 2\n")
+	w, err := WatchConfigFile(path, time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	assert.Equal(t, Level(2), logging.verbosity.get())
+
+	write("verbosity: 5\nseverity: warning\n")
+	assert.Eventually(t, func() bool {
+		c := CurrentConfig()
+		return c.Verbosity == 5 && c.Severity == "WARNING"
+	}, time.Second, time.Millisecond)
+
+	// An invalid file keeps the current configuration.
+	write("verbosity: -1\n")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, Level(5), logging.verbosity.get())
+
+	// Keys which are removed go back to their value from before watching.
+	write("verbosity: 1\n")
+	assert.Eventually(t, func() bool {
+		c := CurrentConfig()
+		return c.Verbosity == 1 && c.Severity == prev.Severity
+	}, time.Second, time.Millisecond)
+
+	w.Stop()
+	write("verbosity: 7\n")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, Level(1), logging.verbosity.get())
+}
+
+func TestWatchConfigFileSIGHUP(t *testing.T) {
+	saveConfig(t)
+
+	path := filepath.Join(t.TempDir(), "xlog.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("verbosity: 2\n"), 0o600))
+	// Polling is disabled, only the signal triggers reloading.
+	w, err := WatchConfigFile(path, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+
+	assert.NoError(t, os.WriteFile(path, []byte("verbosity: 3\n"), 0o600))
+	w.signals <- syscall.SIGHUP
+	assert.Eventually(t, func() bool {
+		return logging.verbosity.get() == 3
+	}, time.Second, time.Millisecond)
+}
+
+func TestWatchConfigFileMissing(t *testing.T) {
+	_, err := WatchConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), time.Millisecond)
+	assert.Error(t, err)
+}
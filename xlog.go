@@ -1,57 +1,97 @@
 package xlog
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"runtime"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"syscall"
 
 	"github.com/tomhjx/xlog/internal/severity"
+	"github.com/tomhjx/xlog/lib/zapr"
+	"github.com/tomhjx/xlog/option"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // severityValue identifies the sort of log: info, warning etc. It also implements
 // the flag.Value interface. The -stderrthreshold flag is of type severity and
 // should be modified only through the flag.Value interface. The values match
 // the corresponding constants in C++.
+//
+// The threshold is stored as a zap.AtomicLevel which is shared with the
+// zap core of the global logger, so both always agree on it.
 type severityValue struct {
-	severity.Severity
+	level zap.AtomicLevel
+}
+
+// newSeverityValue returns a severityValue with the threshold s.
+func newSeverityValue(s severity.Severity) severityValue {
+	return severityValue{level: zap.NewAtomicLevelAt(zapr.SeverityLevel(s))}
 }
 
 // get returns the value of the severity.
 func (s *severityValue) get() severity.Severity {
-	return severity.Severity(atomic.LoadInt32((*int32)(&s.Severity)))
+	return zapr.LevelSeverity(s.level.Level())
 }
 
 // set sets the value of the severity.
 func (s *severityValue) set(val severity.Severity) {
-	atomic.StoreInt32((*int32)(&s.Severity), int32(val))
+	s.level.SetLevel(zapr.SeverityLevel(val))
 }
 
 // String is part of the flag.Value interface.
 func (s *severityValue) String() string {
-	return strconv.FormatInt(int64(s.Severity), 10)
+	if s.level == (zap.AtomicLevel{}) {
+		// The zero value, flag.isZeroValue instantiates it.
+		return "0"
+	}
+	return strconv.FormatInt(int64(s.get()), 10)
 }
 
 // Get is part of the flag.Getter interface.
 func (s *severityValue) Get() interface{} {
-	return s.Severity
+	return s.get()
+}
+
+// Type is part of the pflag.Value interface.
+func (s *severityValue) Type() string {
+	return "severity"
 }
 
 // Set is part of the flag.Value interface.
 func (s *severityValue) Set(value string) error {
-	var threshold severity.Severity
+	threshold, err := parseSeverity(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if s == &logging.severity {
+		logging.stopRevert(&logging.severityRevert)
+	}
+	s.set(threshold)
+	return nil
+}
+
+// parseSeverity accepts a severity name or its numeric value.
+func parseSeverity(value string) (severity.Severity, error) {
 	// Is it a known name?
 	if v, ok := severity.ByName(value); ok {
-		threshold = v
-	} else {
-		v, err := strconv.ParseInt(value, 10, 32)
-		if err != nil {
-			return err
-		}
-		threshold = severity.Severity(v)
+		return v, nil
 	}
-	logging.severity.set(threshold)
-	return nil
+	v, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown severity %q", value)
+	}
+	if v < int64(severity.InfoLog) || v >= severity.NumSeverity {
+		return 0, fmt.Errorf("severity %d out of range", v)
+	}
+	return severity.Severity(v), nil
 }
 
 // Level is treated as a sync/atomic int32.
@@ -81,24 +121,70 @@ func (l *Level) Get() interface{} {
 	return *l
 }
 
+// Type is part of the pflag.Value interface.
+func (l *Level) Type() string {
+	return "Level"
+}
+
 // Set is part of the flag.Value interface.
 func (l *Level) Set(value string) error {
-	v, err := strconv.ParseInt(value, 10, 32)
+	v, err := parseLevel(value)
 	if err != nil {
 		return err
 	}
 	logging.mu.Lock()
 	defer logging.mu.Unlock()
-	logging.setVState(Level(v))
+	logging.stopRevert(&logging.verbosityRevert)
+	logging.setVState(v, logging.vmodule.filter, false)
 	return nil
 }
 
+// parseLevel parses the numeric value of a Level.
+func parseLevel(value string) (Level, error) {
+	v, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return Level(v), nil
+}
+
 // setVState sets a consistent state for V logging.
 // l.mu is held.
-func (l *loggingT) setVState(verbosity Level) {
+func (l *loggingT) setVState(verbosity Level, filter []modulePat, setFilter bool) {
+	// Turn verbosity off so V will not fire while we are in transition.
+	l.verbosity.set(0)
+	// Ditto for filter length.
+	atomic.StoreInt32(&l.filterLength, 0)
+
+	// Set the new filters and wipe the pc->Level map if the filter has changed.
+	if setFilter {
+		l.vmodule.filter = filter
+		l.vmap = make(map[uintptr]Level)
+	}
+
+	// Things are consistent now, so enable filtering and verbosity.
+	// They are enabled in order opposite to that in V.
+	atomic.StoreInt32(&l.filterLength, int32(len(l.vmodule.filter)))
 	l.verbosity.set(verbosity)
 }
 
+// levelEnabler is the zapcore.LevelEnabler of the loggers built by xlog.
+// Entries are subject to the severity threshold, with V levels counting
+// as info, and V levels to the verbosity. V raises the verbosity of the
+// call sites which -vmodule enables, see newVerbose.
+type levelEnabler struct {
+	severity  zap.AtomicLevel
+	verbosity *Level
+}
+
+// Enabled implements zapcore.LevelEnabler.
+func (e levelEnabler) Enabled(lvl zapcore.Level) bool {
+	if lvl >= zapcore.InfoLevel {
+		return e.severity.Enabled(lvl)
+	}
+	return e.severity.Enabled(zapcore.InfoLevel) && Level(-lvl) <= e.verbosity.get()
+}
+
 func (l *loggingT) println(s severity.Severity, logger *logWriter, args ...interface{}) {
 	l.printlnDepth(s, logger, 1, args...)
 }
@@ -116,26 +202,58 @@ func (l *loggingT) printDepth(s severity.Severity, logger *logWriter, depth int,
 }
 
 func (l *loggingT) output(s severity.Severity, logger *logWriter, depth int, msg string) {
-	if s < l.severity.Severity {
+	if s < l.severity.get() {
 		return
 	}
 	depth += 3
-	if s == severity.ErrorLog || s == severity.FatalLog {
-		logger.WithCallDepth(depth).Error(nil, msg)
-	} else {
-		logger.WithCallDepth(depth).Info(msg)
-	}
+	logger.write(s, depth, nil, msg)
 
 	if s == severity.FatalLog {
+		l.exit()
+	}
 
-		// If we got here via Exit rather than Fatal, print no stacks.
-		if atomic.LoadUint32(&fatalNoStacks) > 0 {
-			OsExit(1)
-		}
+}
 
-		OsExit(255) // C++ uses -1, which is silly because it's anded with 255 anyway.
+// exit terminates the program after a message was logged to the FATAL log.
+func (l *loggingT) exit() {
+	// The daemon must not flush while the process exits. Waiting for it
+	// could block forever if it hangs in a flush, the final flush below
+	// is bounded by a timeout instead.
+	l.flushD.signalStop()
+
+	// If we got here via Exit rather than Fatal, print no stacks.
+	if atomic.LoadUint32(&fatalNoStacks) > 0 {
+		timeoutFlush(ExitFlushTimeout)
+		OsExit(1)
+		return
 	}
 
+	// Dump all goroutine stacks before exiting.
+	// First, make sure we see the trace for the current goroutine on standard error.
+	os.Stderr.Write(stacks(false))
+	// Write the stack trace for all goroutines to the files.
+	zapr.WriteSinks(stacks(true)) // If we get a write error, we'll still exit below.
+	timeoutFlush(ExitFlushTimeout)
+	OsExit(255) // C++ uses -1, which is silly because it's anded with 255 anyway.
+}
+
+// stacks is a wrapper for runtime.Stack that attempts to recover the data for all goroutines.
+func stacks(all bool) []byte {
+	// We don't know how big the traces are, so grow a few times if they don't fit. Start large, though.
+	n := 10000
+	if all {
+		n = 100000
+	}
+	var trace []byte
+	for i := 0; i < 5; i++ {
+		trace = make([]byte, n)
+		nbytes := runtime.Stack(trace, all)
+		if nbytes < len(trace) {
+			return trace[:nbytes]
+		}
+		n *= 2
+	}
+	return trace
 }
 
 func (l *loggingT) printf(s severity.Severity, logger *logWriter, format string, args ...interface{}) {
@@ -148,22 +266,79 @@ func (l *loggingT) printfDepth(s severity.Severity, logger *logWriter, depth int
 
 // if loggr is specified, will call loggr.Error, otherwise output with logging module.
 func (l *loggingT) errorS(err error, logger *logWriter, depth int, msg string, keysAndValues ...interface{}) {
-	if severity.ErrorLog < l.severity.Severity {
+	if severity.ErrorLog < l.severity.get() {
 		return
 	}
-	logger.WithCallDepth(depth+1).Error(err, msg, keysAndValues...)
+	// Skip this function and the exported one which called it.
+	logger.write(severity.ErrorLog, depth+2, err, msg, keysAndValues...)
+}
+
+// fatalS structured logs to the FATAL log and then terminates the program.
+func (l *loggingT) fatalS(logger *logWriter, depth int, msg string, keysAndValues ...interface{}) {
+	// Skip this function and the exported one which called it.
+	logger.write(severity.FatalLog, depth+2, nil, msg, keysAndValues...)
+	l.exit()
 }
 
 // if loggr is specified, will call loggr.Info, otherwise output with logging module.
 func (l *loggingT) infoS(logger *logWriter, depth int, msg string, keysAndValues ...interface{}) {
-	if severity.InfoLog < l.severity.Severity {
+	if severity.InfoLog < l.severity.get() {
 		return
 	}
-	logger.WithCallDepth(depth+1).Info(msg, keysAndValues...)
+	// Skip this function and the exported one which called it.
+	logger.write(severity.InfoLog, depth+2, nil, msg, keysAndValues...)
 }
 
 func V(level Level) Verbose {
-	return Verbose{logging.verbosity.get() >= level, newLogWriter(GlobalLogger().Logger)}
+	return VDepth(1, level)
+}
+
+// VDepth is a variant of V that accepts a number of stack frames that will
+// be skipped when checking the -vmodule patterns. VDepth(0) is equivalent to
+// V().
+func VDepth(depth int, level Level) Verbose {
+	return newVerbose(GlobalLogger().Logger, level, depth+1)
+}
+
+// VLogger is like V, but logs with logger instead of the global logger,
+// keeping its names and values. The rules of SetNameVerbosity for the
+// name of logger take precedence over the verbosity and -vmodule.
+func VLogger(logger Logger, level Level) Verbose {
+	return newVerbose(logger, level, 1)
+}
+
+// VCtx is like VLogger for the logger returned by FromContext(ctx).
+func VCtx(ctx context.Context, level Level) Verbose {
+	return newVerbose(FromContext(ctx), level, 1)
+}
+
+// newVerbose returns the Verbose for logging at level with logger. depth
+// is the number of stack frames between the caller and the call site
+// whose -vmodule pattern applies.
+func newVerbose(logger Logger, level Level, depth int) Verbose {
+	v := Verbose{logger: newLogWriter(logger.V(int(level)))}
+	if sink, ok := logger.GetSink().(zapr.NameVerbositySink); ok {
+		if nameLevel, ok := sink.NameLevel(); ok {
+			v.enabled = Level(nameLevel) >= level
+			return v
+		}
+	}
+
+	// This function tries hard to be cheap unless there's work to do.
+	// The fast path is one atomic load of the verbosity. The vmodule
+	// filter is only consulted if the global verbosity is too low.
+	if logging.verbosity.get() >= level {
+		v.enabled = true
+		return v
+	}
+	if logging.vmoduleEnabled(level, depth+1) {
+		v.enabled = true
+		// The core only passes levels up to the verbosity.
+		if sink, ok := logger.GetSink().(zapr.VerbositySink); ok {
+			v.logger = newLogWriter(logger.WithSink(sink.WithVerbosity(int(level))).V(int(level)))
+		}
+	}
+	return v
 }
 
 // Verbose is a boolean type that implements Infof (like Printf) etc.
@@ -173,6 +348,13 @@ type Verbose struct {
 	logger  *logWriter
 }
 
+// Enabled will return true if this log level is enabled, guarded by the value
+// of v.
+// See the documentation of V for usage.
+func (v Verbose) Enabled() bool {
+	return v.enabled
+}
+
 // Info is equivalent to the global Info function, guarded by the value of v.
 // See the documentation of V for usage.
 func (v Verbose) Info(args ...interface{}) {
@@ -453,80 +635,183 @@ func InfoSDepth(depth int, msg string, keysAndValues ...interface{}) {
 	logging.infoS(GlobalLogger(), depth, msg, keysAndValues...)
 }
 
-// // fatalNoStacks is non-zero if we are to exit without dumping goroutine stacks.
-// // It allows Exit and relatives to use the Fatal logs.
-// var fatalNoStacks uint32
-
-// // Exit logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
-// // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
-// func Exit(args ...interface{}) {
-// 	atomic.StoreUint32(&fatalNoStacks, 1)
-// 	logging.print(severity.FatalLog, logging.logger, logging.filter, args...)
-// }
-
-// // ExitDepth acts as Exit but uses depth to determine which call frame to log.
-// // ExitDepth(0, "msg") is the same as Exit("msg").
-// func ExitDepth(depth int, args ...interface{}) {
-// 	atomic.StoreUint32(&fatalNoStacks, 1)
-// 	logging.printDepth(severity.FatalLog, logging.logger, logging.filter, depth, args...)
-// }
-
-// // Exitln logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
-// func Exitln(args ...interface{}) {
-// 	atomic.StoreUint32(&fatalNoStacks, 1)
-// 	logging.println(severity.FatalLog, logging.logger, logging.filter, args...)
-// }
-
-// // ExitlnDepth acts as Exitln but uses depth to determine which call frame to log.
-// // ExitlnDepth(0, "msg") is the same as Exitln("msg").
-// func ExitlnDepth(depth int, args ...interface{}) {
-// 	atomic.StoreUint32(&fatalNoStacks, 1)
-// 	logging.printlnDepth(severity.FatalLog, logging.logger, logging.filter, depth, args...)
-// }
-
-// // Exitf logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
-// // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
-// func Exitf(format string, args ...interface{}) {
-// 	atomic.StoreUint32(&fatalNoStacks, 1)
-// 	logging.printf(severity.FatalLog, logging.logger, logging.filter, format, args...)
-// }
-
-// // ExitfDepth acts as Exitf but uses depth to determine which call frame to log.
-// // ExitfDepth(0, "msg", args...) is the same as Exitf("msg", args...).
+// Exit logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func Exit(args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.print(severity.FatalLog, GlobalLogger(), args...)
+}
+
+// ExitDepth acts as Exit but uses depth to determine which call frame to log.
+// ExitDepth(0, "msg") is the same as Exit("msg").
+func ExitDepth(depth int, args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.printDepth(severity.FatalLog, GlobalLogger(), depth, args...)
+}
+
+// Exitln logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
+func Exitln(args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.println(severity.FatalLog, GlobalLogger(), args...)
+}
+
+// ExitlnDepth acts as Exitln but uses depth to determine which call frame to log.
+// ExitlnDepth(0, "msg") is the same as Exitln("msg").
+func ExitlnDepth(depth int, args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.printlnDepth(severity.FatalLog, GlobalLogger(), depth, args...)
+}
+
+// Exitf logs to the FATAL, ERROR, WARNING, and INFO logs, then calls OsExit(1).
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func Exitf(format string, args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.printf(severity.FatalLog, GlobalLogger(), format, args...)
+}
+
+// ExitfDepth acts as Exitf but uses depth to determine which call frame to log.
+// ExitfDepth(0, "msg", args...) is the same as Exitf("msg", args...).
+func ExitfDepth(depth int, format string, args ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.printfDepth(severity.FatalLog, GlobalLogger(), depth, format, args...)
+}
+
+// ExitS structured logs to the FATAL, ERROR, WARNING, and INFO logs,
+// then calls OsExit(1).
+// The msg argument used to add constant description to the log line.
+// The key/value pairs would be join by "=" ; a newline is always appended.
 //
-//	func ExitfDepth(depth int, format string, args ...interface{}) {
-//		atomic.StoreUint32(&fatalNoStacks, 1)
-//		logging.printfDepth(severity.FatalLog, logging.logger, logging.filter, depth, format, args...)
-//	}
+// Basic examples:
+// >> xlog.ExitS("Failed to load configuration", "path", path)
+// output:
+// >> F1025 00:15:15.525108       1 main.go:42] "Failed to load configuration" path="/etc/app.yaml"
+func ExitS(msg string, keysAndValues ...interface{}) {
+	atomic.StoreUint32(&fatalNoStacks, 1)
+	logging.fatalS(GlobalLogger(), 0, msg, keysAndValues...)
+}
 
 type loggingT struct {
 	settings
 	// mu protects the remaining elements of this structure and the fields
 	// in settingsT which need a mutex lock.
 	mu sync.Mutex
+
+	// flushD holds a flushDaemon that periodically syncs the log outputs.
+	flushD *flushDaemon
+
+	// logger is the global logger. It gets swapped atomically so that it
+	// can be replaced while other goroutines are logging.
+	logger atomic.Pointer[logWriter]
+
+	// contextualLoggingEnabled controls whether contextual logging is
+	// active. Disabling it may have some small performance benefit. It
+	// is atomic because Configure may change it while logging.
+	contextualLoggingEnabled atomic.Bool
+
+	// vmap is a cache of the V Level for each V() call site, identified by PC.
+	// It is wiped whenever the vmodule flag changes state.
+	vmap map[uintptr]Level
+	// filterLength stores the length of the vmodule filter slice.
+	filterLength int32
+
+	// verbosityRevert and severityRevert undo temporary changes of
+	// the verbosity and the severity threshold.
+	verbosityRevert *revert
+	severityRevert  *revert
 }
 
-// Flush flushes all pending log I/O.
+// Flush flushes all pending log I/O. Failures are reported on stderr.
 func Flush() {
+	if err := FlushErr(); err != nil {
+		fmt.Fprintln(os.Stderr, "xlog: Flush failed:", err)
+	}
 }
 
-type settings struct {
-	// contextualLoggingEnabled controls whether contextual logging is
-	// active. Disabling it may have some small performance benefit.
-	contextualLoggingEnabled bool
-	logger                   *logWriter
+// FlushErr is like Flush but returns the failures instead. It syncs the
+// zap logger behind the global logger, if there is one, and every open
+// log file, continuing after the first failure.
+func FlushErr() error {
+	return logging.flushAll()
+}
 
-	severity severityValue
+// flushAll syncs the global logger and all file sinks.
+func (l *loggingT) flushAll() error {
+	var errs []error
+	if lw := l.logger.Load(); lw != nil {
+		if u, ok := lw.GetSink().(zapr.Underlier); ok {
+			if err := u.GetUnderlying().Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := zapr.SyncSinks(); err != nil {
+		errs = append(errs, err)
+	}
+	return ignoreUnsyncable(errors.Join(errs...))
+}
+
+// ignoreUnsyncable drops the errors reported when syncing stderr or
+// stdout while they are a terminal or a pipe. Those outputs are not
+// buffered, so there is nothing to report.
+func ignoreUnsyncable(err error) error {
+	if err == nil {
+		return nil
+	}
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range u.Unwrap() {
+			if e = ignoreUnsyncable(e); e != nil {
+				errs = append(errs, e)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+	return err
+}
 
-	verbosity      Level // V logging level
+type settings struct {
+	severity severityValue
+	// stderrThreshold is the threshold for entries written to stderr
+	// while they also go to a file.
+	stderrThreshold severityValue
+	// toStderr makes the log file unused, everything goes to stderr.
+	toStderr bool
+	// alsoToStderr sends every entry to stderr, not just those at or
+	// above stderrThreshold.
+	alsoToStderr bool
+
+	verbosity Level      // V logging level
+	vmodule   moduleSpec // The state of the -vmodule flag.
+	// vname holds the verbosity of named loggers. It is shared with
+	// the loggers built from the settings.
+	vname *zapr.NameLevels
+	// backtraceAt is the call site whose entries get a stack trace. It
+	// is shared with the loggers built from the settings.
+	backtraceAt    *zapr.TraceLocation
 	file           string
 	fileMaxSizeMB  int
 	fileMaxAgeDay  int
 	fileMaxBackups int
+	fileCompress   bool
+	fileUTC        bool
+	fileMode       os.FileMode
+	format         string
+	color          string
+	encoder        option.EncoderConfig
+}
+
+var logging = loggingT{
+	settings: settings{
+		severity:        newSeverityValue(severity.InfoLog),
+		stderrThreshold: newSeverityValue(severity.ErrorLog),
+		vname:           &zapr.NameLevels{},
+		backtraceAt:     &zapr.TraceLocation{},
+	},
 }
 
-var logging loggingT
-
 func GetSeverityNames() []string {
 	return severity.Name
 }
@@ -1,11 +1,20 @@
 package xlog
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr/funcr"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/tomhjx/xlog/internal/severity"
@@ -165,3 +174,250 @@ func TestGlobalLogger(t *testing.T) {
 	}
 
 }
+
+func TestFlush(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "flush.log")
+	Info("flush me")
+	assert.NoError(t, FlushErr())
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "flush me")
+}
+
+func TestSeverityThreshold(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "severity.log")
+
+	// The threshold changes without rebuilding the logger and applies
+	// to xlog as well as to loggers using the zap core directly.
+	SetSeverity(severity.WarningLog)
+	Info("xlog info")
+	Warning("xlog warning")
+	GlobalLogger().Info("logr info")
+	GlobalLogger().Error(nil, "logr error")
+	SetSeverity(severity.InfoLog)
+	GlobalLogger().Info("logr info again")
+	assert.NoError(t, FlushErr())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(r), "xlog info")
+	assert.Contains(t, string(r), `"level":"W","ts"`)
+	assert.Contains(t, string(r), "xlog warning")
+	assert.NotContains(t, string(r), `"logr info"`)
+	assert.Contains(t, string(r), "logr error")
+	assert.Contains(t, string(r), "logr info again")
+}
+
+func TestVerboseLevel(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	SetVerbosity(2)
+	logFile := logToFile(t, "verbose.log")
+
+	V(2).InfoS("xlog v2")
+	V(3).InfoS("xlog v3")
+	Info("xlog v0")
+	// The zap core honors the verbosity without V.
+	GlobalLogger().V(2).Info("logr v2")
+	GlobalLogger().V(3).Info("logr v3")
+	assert.NoError(t, FlushErr())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `"msg":"xlog v2","v":2`, string(r))
+	assert.Regexp(t, `"msg":"xlog v0","v":0`, string(r))
+	assert.Regexp(t, `"msg":"logr v2","v":2`, string(r))
+	assert.NotContains(t, string(r), "v3")
+
+	// Levels enabled by vmodule get through the zap core.
+	SetVerbosity(0)
+	assert.NoError(t, SetVModule("xlog_test=3"))
+	defer func() { assert.NoError(t, SetVModule("")) }()
+	V(3).InfoS("vmodule v3")
+	// Loggers used without V only get the verbosity.
+	assert.False(t, GlobalLogger().V(3).Enabled())
+	GlobalLogger().V(3).Info("logr vmodule v3")
+	FromContext(context.Background()).V(3).Info("context vmodule v3")
+	assert.NoError(t, FlushErr())
+	r, err = os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `"msg":"vmodule v3","v":3`, string(r))
+	assert.NotContains(t, string(r), "logr vmodule")
+	assert.NotContains(t, string(r), "context vmodule")
+
+	// The severity threshold applies to all V levels.
+	SetSeverity(severity.WarningLog)
+	defer SetSeverity(severity.InfoLog)
+	assert.False(t, GlobalLogger().V(1).Enabled())
+}
+
+func TestBacktraceAt(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "backtrace.log")
+
+	_, _, line, _ := runtime.Caller(0)
+	location := "xlog_test.go:" + strconv.Itoa(line+3)
+	assert.NoError(t, SetBacktraceAt(location))
+	InfoS("traced")
+	InfoS("not traced")
+	assert.Error(t, SetBacktraceAt("xlog_test.go"))
+	assert.NoError(t, FlushErr())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Regexp(t, `"caller":"[^"]*/`+regexp.QuoteMeta(location)+`","msg":"traced","v":0,"stacktrace":"goroutine `, string(r))
+	assert.Regexp(t, `"msg":"not traced","v":0}`, string(r))
+	assert.Equal(t, location, CurrentConfig().BacktraceAt)
+}
+
+func TestStderrThreshold(t *testing.T) {
+	saveConfig(t)
+	prevStderr := os.Stderr
+	defer func() { os.Stderr = prevStderr }()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "file.log")
+	// logTo builds a new global logger with stderr redirected to a
+	// file, logs an info and an error entry and returns what got
+	// written to stderr and to the log file.
+	logTo := func(name string, modify func(c *Config)) (stderr, file string) {
+		f, err := os.Create(filepath.Join(dir, name+".stderr"))
+		assert.NoError(t, err)
+		// Stays open for the logger, which may still be used.
+		t.Cleanup(func() { f.Close() })
+		os.Stderr = f
+		c := Config{File: logFile}
+		modify(&c)
+		assert.NoError(t, Configure(c))
+		InfoS(name + " info")
+		ErrorS(nil, name+" error")
+		assert.NoError(t, FlushErr())
+
+		r, err := os.ReadFile(f.Name())
+		assert.NoError(t, err)
+		stderr = string(r)
+		r, err = os.ReadFile(logFile)
+		assert.NoError(t, err)
+		return stderr, string(r)
+	}
+
+	stderr, file := logTo("threshold", func(c *Config) {})
+	assert.NotContains(t, stderr, "threshold info")
+	assert.Contains(t, stderr, "threshold error")
+	assert.Contains(t, file, "threshold info")
+	assert.Contains(t, file, "threshold error")
+
+	// The threshold can change at any time.
+	SetStderrThreshold(severity.InfoLog)
+	InfoS("lowered info")
+	assert.NoError(t, FlushErr())
+	r, err := os.ReadFile(filepath.Join(dir, "threshold.stderr"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "lowered info")
+
+	stderr, file = logTo("also", func(c *Config) { c.AlsoLogToStderr = true })
+	assert.Contains(t, stderr, "also info")
+	assert.Contains(t, stderr, "also error")
+	assert.Contains(t, file, "also info")
+
+	stderr, file = logTo("only", func(c *Config) { c.LogToStderr = true })
+	assert.Contains(t, stderr, "only info")
+	assert.Contains(t, stderr, "only error")
+	assert.NotContains(t, file, "only")
+}
+
+// captureGlobalLogs replaces the global logger with one writing into the
+// returned buffer until the test ends.
+func captureGlobalLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	// Bypass SetLogger, it would close the previous logger.
+	prev := logging.logger.Swap(newLogWriter(funcr.New(func(prefix, args string) {
+		fmt.Fprintln(buf, prefix, args)
+	}, funcr.Options{})))
+	t.Cleanup(func() {
+		logging.logger.Store(prev)
+	})
+	return buf
+}
+
+// swapOsExit records the exit codes passed to OsExit until the test ends.
+func swapOsExit(t *testing.T) *[]int {
+	codes := &[]int{}
+	prev := OsExit
+	OsExit = func(code int) {
+		*codes = append(*codes, code)
+	}
+	t.Cleanup(func() {
+		OsExit = prev
+		atomic.StoreUint32(&fatalNoStacks, 0)
+	})
+	return codes
+}
+
+func TestExit(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	tests := map[string]func(msg string){
+		"Exit":        func(msg string) { Exit(msg) },
+		"ExitDepth":   func(msg string) { ExitDepth(0, msg) },
+		"Exitln":      func(msg string) { Exitln(msg) },
+		"ExitlnDepth": func(msg string) { ExitlnDepth(0, msg) },
+		"Exitf":       func(msg string) { Exitf("%s", msg) },
+		"ExitfDepth":  func(msg string) { ExitfDepth(0, "%s", msg) },
+		"ExitS":       func(msg string) { ExitS(msg, "key", "value") },
+	}
+	for name, exit := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := captureGlobalLogs(t)
+			codes := swapOsExit(t)
+			msg := fmt.Sprint(name, "#", createTestingUniqueID())
+			exit(msg)
+			assert.Equal(t, []int{1}, *codes)
+			assert.Contains(t, buf.String(), msg)
+		})
+	}
+}
+
+func TestFatal(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "fatal.log")
+	codes := swapOsExit(t)
+	StartFlushDaemon(time.Hour)
+
+	msg := fmt.Sprint("Fatal#", createTestingUniqueID())
+	Fatal(msg)
+	assert.Equal(t, []int{255}, *codes)
+	assert.False(t, logging.flushD.isRunning())
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	rlog := string(r)
+	assert.Contains(t, rlog, msg)
+	assert.Contains(t, rlog, "goroutine ")
+	assert.Contains(t, rlog, "TestFatal")
+	assert.Less(t, strings.Index(rlog, msg), strings.Index(rlog, "goroutine "))
+}
+
+func TestFlushAndExit(t *testing.T) {
+	codes := swapOsExit(t)
+	StartFlushDaemon(time.Hour)
+
+	FlushAndExit(time.Second, 3)
+	assert.Equal(t, []int{3}, *codes)
+	assert.False(t, logging.flushD.isRunning())
+}
+
+func TestExitNoStacks(t *testing.T) {
+	SetSeverity(severity.InfoLog)
+	logFile := logToFile(t, "exit.log")
+	codes := swapOsExit(t)
+
+	Exit("exit without stacks")
+	assert.Equal(t, []int{1}, *codes)
+
+	r, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(r), "exit without stacks")
+	assert.NotContains(t, string(r), "goroutine ")
+	assert.NotContains(t, string(r), "stacktrace")
+}